@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+
+	"diamond-mosaic/internal/db"
+)
+
+// runMigrate выполняет подкоманду migrate: применяет миграции, загружает палитру
+// из palette_seed (если задан, то всегда — с обновлением существующих цветов;
+// иначе в пустую таблицу — встроенный набор DMC) и печатает версию схемы БД.
+// Флаги те же, что у сервера.
+func runMigrate(args []string) {
+	cfg := loadConfig(args)
+	sqlDB, err := db.Open(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Ошибка подключения к БД: %v", err)
+	}
+	defer sqlDB.Close()
+	if err := prepareDatabase(sqlDB, cfg.PaletteSeed, true); err != nil {
+		log.Fatalf("Ошибка подготовки БД: %v", err)
+	}
+}
+
+// prepareDatabase применяет миграции и заполняет палитру из CSV-файла seedPath.
+// Без force палитра загружается, только если таблица palette пуста. Без seedPath
+// пустая таблица заполняется встроенным стандартным набором DMC.
+func prepareDatabase(sqlDB *sql.DB, seedPath string, force bool) error {
+	ctx := context.Background()
+
+	// 1. Миграции
+	applied, err := db.Migrate(ctx, sqlDB)
+	if err != nil {
+		return err
+	}
+	for _, m := range applied {
+		log.Printf("Применена миграция %04d_%s", m.Version, m.Name)
+	}
+	version, err := db.SchemaVersion(ctx, sqlDB)
+	if err != nil {
+		return err
+	}
+	log.Printf("Версия схемы БД: %d", version)
+
+	// 2. Палитра
+	size, err := db.PaletteSize(ctx, sqlDB)
+	if err != nil {
+		return err
+	}
+	if seedPath == "" {
+		if size > 0 {
+			return nil
+		}
+		n, err := db.SeedPalette(ctx, sqlDB, db.StandardPalette())
+		if err != nil {
+			return err
+		}
+		log.Printf("Загружено в палитру цветов из встроенного набора DMC: %d", n)
+		return nil
+	}
+	if size > 0 && !force {
+		return nil
+	}
+	f, err := os.Open(seedPath)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия файла палитры: %w", err)
+	}
+	defer f.Close()
+	n, err := db.SeedPalette(ctx, sqlDB, f)
+	if err != nil {
+		return err
+	}
+	log.Printf("Загружено в палитру цветов из %s: %d", seedPath, n)
+	return nil
+}
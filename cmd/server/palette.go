@@ -0,0 +1,78 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"diamond-mosaic/internal/db"
+)
+
+// loadPalette читает включённые цвета из БД, подставляет остатки из файла stockFile
+// (если задан). Различимые цвета отбирает хранилище палитры (db.NewPaletteHolder).
+func loadPalette(sqlDB *sql.DB, stockFile string) ([]db.PaletteColor, error) {
+	palette, err := db.LoadPaletteFrom(sqlDB)
+	if err != nil {
+		return nil, err
+	}
+	if stockFile != "" {
+		f, err := os.Open(stockFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		stock, err := db.ReadStock(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", stockFile, err)
+		}
+		palette = db.ApplyStock(palette, stock)
+	}
+	return palette, nil
+}
+
+// paletteReloader перечитывает палитру из БД в хранилище, которым пользуются обработчики.
+type paletteReloader struct {
+	palettes  *db.PaletteHolder
+	sqlDB     *sql.DB
+	stockFile string
+}
+
+// reload перечитывает палитру; reason попадает в журнал. При ошибке остаётся прежняя палитра,
+// запросы, начатые до перезагрузки, досчитываются со своим снимком.
+func (p *paletteReloader) reload(reason string) {
+	snap, changed, err := p.palettes.Reload(func() ([]db.PaletteColor, error) {
+		return loadPalette(p.sqlDB, p.stockFile)
+	})
+	if err != nil {
+		log.Printf("Ошибка перезагрузки палитры (%s): %v", reason, err)
+		return
+	}
+	if changed {
+		log.Printf("Палитра перезагружена (%s), цветов: %d (версия %s)", reason, snap.Len(), snap.Version)
+	}
+}
+
+// watch перезагружает палитру по SIGHUP и, если interval больше нуля, по таймеру.
+// Работает до завершения процесса.
+func (p *paletteReloader) watch(interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-hup:
+			p.reload("SIGHUP")
+		case <-tick:
+			p.reload("по таймеру")
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Package fonts встраивает шрифт DejaVu Sans в бинарник и даёт общие
+// функции измерения символов для PNG- и PDF-рендеринга схемы.
+package fonts
+
+import (
+	_ "embed"
+	"fmt"
+	"sync"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// DejaVuSans — содержимое файла DejaVuSans.ttf, встроенное при сборке.
+//
+//go:embed DejaVuSans.ttf
+var DejaVuSans []byte
+
+// measureSize — размер шрифта, при котором измеряются символы.
+// Хинтинг отключён, поэтому результат масштабируется линейно на любой размер.
+const measureSize = 1000.0
+
+var (
+	parseOnce sync.Once
+	parsed    *truetype.Font
+	parseErr  error
+
+	measureMu   sync.Mutex
+	measureFace font.Face
+	boundsCache = map[string]inkBox{}
+)
+
+// inkBox — габариты «чернил» символа в долях кегля относительно точки начала строки.
+type inkBox struct {
+	minX, minY, maxX, maxY float64
+}
+
+// Default возвращает встроенный шрифт DejaVu Sans, разобранный один раз за время работы.
+func Default() (*truetype.Font, error) {
+	parseOnce.Do(func() {
+		parsed, parseErr = truetype.Parse(DejaVuSans)
+		if parseErr != nil {
+			parseErr = fmt.Errorf("ошибка разбора шрифта DejaVuSans: %w", parseErr)
+		}
+	})
+	return parsed, parseErr
+}
+
+// HasGlyphs сообщает, есть ли во встроенном шрифте все символы строки s.
+func HasGlyphs(s string) bool {
+	f, err := Default()
+	if err != nil {
+		return false
+	}
+	for _, r := range s {
+		if f.Index(r) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// FitSymbol подбирает кегль и точку начала строки, чтобы символ s
+// поместился в квадрат со стороной box и был отцентрован по своим реальным
+// очертаниям. fill — доля стороны квадрата, которую может занять символ.
+// Все величины — в тех же единицах, что и box (пиксели, мм и т.д.);
+// dy отсчитывается вниз от верхнего края квадрата до базовой линии.
+func FitSymbol(s string, box, fill float64) (size, dx, dy float64, err error) {
+	ink, err := measure(s)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	w := ink.maxX - ink.minX
+	h := ink.maxY - ink.minY
+	if w <= 0 || h <= 0 {
+		return box * fill, 0, 0, nil
+	}
+
+	// 1. Кегль ограничен и шириной, и высотой символа
+	size = box * fill / h
+	if byW := box * fill / w; byW < size {
+		size = byW
+	}
+	// 2. Центр «чернил» совмещаем с центром квадрата
+	dx = box/2 - (ink.minX+w/2)*size
+	dy = box/2 - (ink.minY+h/2)*size
+	return size, dx, dy, nil
+}
+
+// measure возвращает габариты символа s в долях кегля (с кешированием).
+func measure(s string) (inkBox, error) {
+	measureMu.Lock()
+	defer measureMu.Unlock()
+
+	if b, ok := boundsCache[s]; ok {
+		return b, nil
+	}
+	if measureFace == nil {
+		f, err := Default()
+		if err != nil {
+			return inkBox{}, err
+		}
+		measureFace = truetype.NewFace(f, &truetype.Options{
+			Size:    measureSize,
+			DPI:     72,
+			Hinting: font.HintingNone,
+		})
+	}
+	bounds, _ := font.BoundString(measureFace, s)
+	b := inkBox{
+		minX: fixedToFloat(bounds.Min.X) / measureSize,
+		minY: fixedToFloat(bounds.Min.Y) / measureSize,
+		maxX: fixedToFloat(bounds.Max.X) / measureSize,
+		maxY: fixedToFloat(bounds.Max.Y) / measureSize,
+	}
+	boundsCache[s] = b
+	return b, nil
+}
+
+// fixedToFloat переводит число 26.6 в float64.
+func fixedToFloat(v fixed.Int26_6) float64 {
+	return float64(v) / 64
+}
@@ -0,0 +1,139 @@
+// Package bom считает комплектацию набора алмазной мозаики (bill of materials):
+// сколько пакетиков каждого цвета нужно положить с учётом запаса, какой холст,
+// инструменты и упаковку, и во что обойдётся набор.
+package bom
+
+import (
+	"math"
+	"sort"
+
+	"diamond-mosaic/internal/db"
+)
+
+// Settings — параметры расчёта комплектации (задаются конфигурацией сервера).
+type Settings struct {
+	BagSize        int     `json:"bag_size"`        // алмазов в одном пакетике
+	SparePercent   float64 `json:"spare_percent"`   // запас сверх нужного количества, %
+	BagPrice       float64 `json:"bag_price"`       // цена пакетика, если у цвета нет своей цены
+	CanvasPriceM2  float64 `json:"canvas_price_m2"` // цена холста с клеевым слоем за м²
+	ToolsPrice     float64 `json:"tools_price"`     // стилус, лоток, воск
+	PackagingPrice float64 `json:"packaging_price"` // коробка и упаковка
+	Currency       string  `json:"currency"`
+}
+
+// DefaultSettings возвращает параметры комплектации по умолчанию (без цен).
+func DefaultSettings() Settings {
+	return Settings{
+		BagSize:      200,
+		SparePercent: 10,
+		Currency:     "RUB",
+	}
+}
+
+// ColorCount — сколько алмазов цвета нужно для схемы.
+type ColorCount struct {
+	Color db.PaletteColor
+	Count int
+}
+
+// ColorLine — строка комплектации по одному цвету.
+type ColorLine struct {
+	DMCCode   string  `json:"dmc_code"`
+	Name      string  `json:"name"`
+	Symbol    string  `json:"symbol"`
+	Drills    int     `json:"drills"`            // алмазов по схеме
+	WithSpare int     `json:"drills_with_spare"` // алмазов с запасом
+	Bags      int     `json:"bags"`
+	BagPrice  float64 `json:"bag_price"`
+	Cost      float64 `json:"cost"`
+}
+
+// ItemLine — строка комплектации с остальными материалами.
+type ItemLine struct {
+	Name      string  `json:"name"`
+	Quantity  float64 `json:"quantity"`
+	Unit      string  `json:"unit"`
+	UnitPrice float64 `json:"unit_price"`
+	Cost      float64 `json:"cost"`
+}
+
+// BOM — комплектация набора и его стоимость.
+type BOM struct {
+	Settings    Settings    `json:"settings"`
+	Colors      []ColorLine `json:"colors"`
+	Items       []ItemLine  `json:"items"`
+	TotalDrills int         `json:"total_drills"`
+	TotalBags   int         `json:"total_bags"`
+	ColorsCost  float64     `json:"colors_cost"`
+	ItemsCost   float64     `json:"items_cost"`
+	Total       float64     `json:"total"`
+}
+
+// Prices собирает цены пакетиков из палитры: код DMC -> цена (только заданные цены).
+func Prices(palette []db.PaletteColor) map[string]float64 {
+	prices := map[string]float64{}
+	for _, pc := range palette {
+		if pc.BagPrice > 0 {
+			prices[pc.DMCCode] = pc.BagPrice
+		}
+	}
+	return prices
+}
+
+// Calculate считает комплектацию по количеству алмазов каждого цвета и размеру холста в см.
+// Цена пакетика берётся из prices по коду DMC, иначе — Settings.BagPrice.
+func Calculate(counts []ColorCount, widthCM, heightCM int, s Settings, prices map[string]float64) *BOM {
+	if s.BagSize <= 0 {
+		s.BagSize = DefaultSettings().BagSize
+	}
+	b := &BOM{Settings: s, Colors: []ColorLine{}}
+
+	// 1. Пакетики по цветам: нужное количество плюс запас, округлённое до целых пакетиков
+	for _, c := range counts {
+		if c.Count <= 0 || c.Color.DMCCode == "BLANK" {
+			continue
+		}
+		withSpare := int(math.Ceil(float64(c.Count) * (1 + s.SparePercent/100)))
+		bags := (withSpare + s.BagSize - 1) / s.BagSize
+		price, ok := prices[c.Color.DMCCode]
+		if !ok {
+			price = s.BagPrice
+		}
+		line := ColorLine{
+			DMCCode:   c.Color.DMCCode,
+			Name:      c.Color.Name,
+			Symbol:    c.Color.Symbol,
+			Drills:    c.Count,
+			WithSpare: withSpare,
+			Bags:      bags,
+			BagPrice:  price,
+			Cost:      money(float64(bags) * price),
+		}
+		b.Colors = append(b.Colors, line)
+		b.TotalDrills += line.Drills
+		b.TotalBags += line.Bags
+		b.ColorsCost += line.Cost
+	}
+	sort.SliceStable(b.Colors, func(i, j int) bool { return b.Colors[i].Drills > b.Colors[j].Drills })
+
+	// 2. Холст, инструменты и упаковка
+	area := float64(widthCM) * float64(heightCM) / 10000
+	b.Items = []ItemLine{
+		{Name: "Холст с клеевым слоем", Quantity: math.Round(area*1000) / 1000, Unit: "м²", UnitPrice: s.CanvasPriceM2, Cost: money(area * s.CanvasPriceM2)},
+		{Name: "Инструменты (стилус, лоток, воск)", Quantity: 1, Unit: "компл.", UnitPrice: s.ToolsPrice, Cost: money(s.ToolsPrice)},
+		{Name: "Упаковка", Quantity: 1, Unit: "шт", UnitPrice: s.PackagingPrice, Cost: money(s.PackagingPrice)},
+	}
+	for _, it := range b.Items {
+		b.ItemsCost += it.Cost
+	}
+
+	b.ColorsCost = money(b.ColorsCost)
+	b.ItemsCost = money(b.ItemsCost)
+	b.Total = money(b.ColorsCost + b.ItemsCost)
+	return b
+}
+
+// money округляет сумму до копеек.
+func money(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,82 @@
+// Package cache хранит результаты генерации по ключу, вычисленному из содержимого:
+// хеша загруженного файла, версии палитры и всех параметров генерации.
+//
+// Есть два хранилища: LRU в памяти и каталог на диске. Tiered объединяет их —
+// сначала ищет в памяти, потом на диске, а найденное на диске поднимает в память.
+// Каждое хранилище ограничено по размеру и считает попадания и промахи.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Cache — хранилище байтовых значений по ключу.
+// Реализации безопасны для одновременного использования.
+type Cache interface {
+	// Get возвращает значение по ключу; ok == false при промахе.
+	Get(key string) (data []byte, ok bool)
+	// Put сохраняет значение. Слишком большие значения не сохраняются.
+	Put(key string, data []byte)
+	// Stats возвращает счётчики хранилища.
+	Stats() Stats
+}
+
+// Stats — счётчики хранилища для метрик.
+type Stats struct {
+	Backend   string  `json:"backend"`
+	Hits      int64   `json:"hits"`
+	Misses    int64   `json:"misses"`
+	Puts      int64   `json:"puts"`
+	Evictions int64   `json:"evictions"`
+	Entries   int     `json:"entries"`
+	Bytes     int64   `json:"bytes"`
+	MaxBytes  int64   `json:"max_bytes"`
+	Levels    []Stats `json:"levels,omitempty"` // для Tiered — счётчики каждого уровня
+}
+
+// maxEntryShare — значение больше MaxBytes/maxEntryShare не кешируется,
+// чтобы одна огромная схема не вытесняла всё остальное.
+const maxEntryShare = 4
+
+// Key вычисляет ключ кеша из частей (хеш файла, версия палитры, параметры...).
+// Части разделяются нулевым байтом, поэтому ("ab", "c") и ("a", "bc") дают разные ключи.
+func Key(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// New собирает кеш по настройкам: memoryBytes — лимит LRU в памяти, dir и diskBytes —
+// каталог и лимит дискового кеша. Нулевой лимит или пустой каталог отключают уровень;
+// если отключены оба, возвращается кеш, который ничего не хранит.
+func New(memoryBytes int64, dir string, diskBytes int64) (Cache, error) {
+	var levels []Cache
+	if memoryBytes > 0 {
+		levels = append(levels, NewLRU(memoryBytes))
+	}
+	if dir != "" && diskBytes > 0 {
+		d, err := NewDisk(dir, diskBytes)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, d)
+	}
+	switch len(levels) {
+	case 0:
+		return Nop{}, nil
+	case 1:
+		return levels[0], nil
+	}
+	return NewTiered(levels...), nil
+}
+
+// Nop — кеш, который ничего не хранит (кеширование выключено).
+type Nop struct{}
+
+func (Nop) Get(string) ([]byte, bool) { return nil, false }
+func (Nop) Put(string, []byte)        {}
+func (Nop) Stats() Stats              { return Stats{Backend: "none"} }
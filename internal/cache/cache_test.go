@@ -0,0 +1,190 @@
+package cache
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// value возвращает значение размером n байт.
+func value(n int) []byte {
+	return bytes.Repeat([]byte{'x'}, n)
+}
+
+// keys — несколько допустимых ключей кеша.
+var keys = []string{Key("a"), Key("b"), Key("c"), Key("d"), Key("e")}
+
+// checkContents проверяет, какие ключи есть в кеше. Get меняет порядок вытеснения,
+// поэтому вызывается в конце теста.
+func checkContents(t *testing.T, c Cache, present, absent []string) {
+	t.Helper()
+	for _, k := range present {
+		if _, ok := c.Get(k); !ok {
+			t.Errorf("ключ %s… вытеснен, хотя должен остаться", k[:8])
+		}
+	}
+	for _, k := range absent {
+		if _, ok := c.Get(k); ok {
+			t.Errorf("ключ %s… остался, хотя должен быть вытеснен", k[:8])
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	if Key("ab", "c") == Key("a", "bc") {
+		t.Error("ключи частей (ab, c) и (a, bc) совпали")
+	}
+	if Key("a", "b") != Key("a", "b") {
+		t.Error("ключ не детерминирован")
+	}
+	if k := Key("a"); !validKey(k) {
+		t.Errorf("ключ %q не проходит validKey", k)
+	}
+	for _, k := range []string{"", "../etc/passwd", strings.Repeat("A", 64), strings.Repeat("g", 64)} {
+		if validKey(k) {
+			t.Errorf("validKey(%q) = true", k)
+		}
+	}
+}
+
+func TestLRUEviction(t *testing.T) {
+	c := NewLRU(100) // значение не больше 25 байт
+	for _, k := range keys[:4] {
+		c.Put(k, value(25))
+	}
+	c.Get(keys[0])            // keys[0] становится последним использованным
+	c.Put(keys[4], value(25)) // вытесняет keys[1]
+	c.Put(keys[2], value(26)) // слишком большое значение не сохраняется и не заменяет старое
+
+	s := c.Stats()
+	if s.Entries != 4 || s.Bytes != 100 || s.Evictions != 1 || s.Puts != 5 || s.Hits != 1 {
+		t.Errorf("счётчики %+v", s)
+	}
+	if data, _ := c.Get(keys[2]); len(data) != 25 {
+		t.Errorf("значение keys[2] длиной %d, ожидалось 25", len(data))
+	}
+	checkContents(t, c, []string{keys[0], keys[3], keys[4]}, []string{keys[1]})
+}
+
+func TestLRUReplace(t *testing.T) {
+	c := NewLRU(100)
+	c.Put(keys[0], value(10))
+	c.Put(keys[0], value(20))
+	if s := c.Stats(); s.Entries != 1 || s.Bytes != 20 {
+		t.Errorf("после замены значения: %+v", s)
+	}
+}
+
+func TestDiskEviction(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewDisk(dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range keys[:4] {
+		c.Put(k, value(25))
+	}
+	c.Get(keys[0])
+	c.Put(keys[4], value(25))
+	c.Put("not-a-key", value(1)) // недопустимый ключ не записывается
+
+	if s := c.Stats(); s.Entries != 4 || s.Bytes != 100 || s.Evictions != 1 {
+		t.Errorf("счётчики %+v", s)
+	}
+	if _, err := os.Stat(c.path(keys[1])); !os.IsNotExist(err) {
+		t.Errorf("файл вытесненного значения остался: %v", err)
+	}
+	checkContents(t, c, []string{keys[0], keys[2], keys[3], keys[4]}, []string{keys[1], "not-a-key"})
+
+	// Файл, удалённый снаружи, — промах, и он забывается
+	os.Remove(c.path(keys[2]))
+	checkContents(t, c, nil, []string{keys[2]})
+	if s := c.Stats(); s.Entries != 3 || s.Bytes != 75 {
+		t.Errorf("после удаления файла: %+v", s)
+	}
+}
+
+func TestDiskReopen(t *testing.T) {
+	dir := t.TempDir()
+	c, err := NewDisk(dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, k := range keys[:4] {
+		c.Put(k, value(20))
+		// порядок вытеснения после перезапуска — по времени изменения файлов
+		mtime := time.Now().Add(time.Duration(i-10) * time.Minute)
+		os.Chtimes(c.path(k), mtime, mtime)
+	}
+	// Посторонние файлы при открытии пропускаются
+	os.WriteFile(filepath.Join(dir, "README"), []byte("not a cache entry"), 0o644)
+
+	// Открываем с меньшим лимитом: самые старые файлы удаляются сразу
+	c, err = NewDisk(dir, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := c.Stats(); s.Entries != 2 || s.Bytes != 40 || s.Evictions != 2 {
+		t.Errorf("после открытия: %+v", s)
+	}
+	checkContents(t, c, []string{keys[2], keys[3]}, []string{keys[0], keys[1]})
+}
+
+func TestTiered(t *testing.T) {
+	mem := NewLRU(40) // значение не больше 10 байт
+	disk, err := NewDisk(t.TempDir(), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewTiered(mem, disk)
+	for _, k := range keys {
+		c.Put(k, value(10)) // в памяти остаются четыре последних
+	}
+	if _, ok := mem.Get(keys[0]); ok {
+		t.Fatal("keys[0] не вытеснен из памяти")
+	}
+
+	// Найденное на диске поднимается в память
+	if _, ok := c.Get(keys[0]); !ok {
+		t.Fatal("keys[0] не найден на диске")
+	}
+	if _, ok := mem.Get(keys[0]); !ok {
+		t.Error("keys[0] не поднят в память")
+	}
+	if _, ok := c.Get(Key("missing")); ok {
+		t.Error("найден отсутствующий ключ")
+	}
+
+	s := c.Stats()
+	if s.Hits != 1 || s.Misses != 1 || len(s.Levels) != 2 || s.Entries != 5 || s.MaxBytes != 1040 {
+		t.Errorf("счётчики %+v", s)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		memory  int64
+		dir     string
+		disk    int64
+		backend string
+	}{
+		{"выключен", 0, "", 0, "none"},
+		{"только память", 100, "", 0, "memory"},
+		{"только диск", 0, t.TempDir(), 100, "disk"},
+		{"диск без лимита", 100, t.TempDir(), 0, "memory"},
+		{"оба уровня", 100, t.TempDir(), 100, "tiered"},
+	}
+	for _, tt := range tests {
+		c, err := New(tt.memory, tt.dir, tt.disk)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := c.Stats().Backend; got != tt.backend {
+			t.Errorf("%s: кеш %q, ожидался %q", tt.name, got, tt.backend)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Disk — кеш в каталоге на диске: одно значение — один файл с именем-ключом.
+// Индекс файлов хранится в памяти и строится при запуске по содержимому каталога,
+// поэтому кеш переживает перезапуск сервера. При переполнении удаляются файлы,
+// к которым дольше всего не обращались.
+type Disk struct {
+	mu    sync.Mutex
+	dir   string
+	max   int64
+	items map[string]*list.Element
+	order *list.List // в начале — последние использованные
+	stats Stats
+}
+
+// diskEntry — файл кеша в индексе.
+type diskEntry struct {
+	key  string
+	size int64
+}
+
+// NewDisk открывает (или создаёт) дисковый кеш в каталоге dir размером не больше maxBytes.
+func NewDisk(dir string, maxBytes int64) (*Disk, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("ошибка создания каталога кеша: %w", err)
+	}
+	c := &Disk{
+		dir:   dir,
+		max:   maxBytes,
+		items: map[string]*list.Element{},
+		order: list.New(),
+		stats: Stats{Backend: "disk", MaxBytes: maxBytes},
+	}
+
+	// 1. Собираем уже лежащие в каталоге файлы, от старых к новым
+	type found struct {
+		key   string
+		size  int64
+		mtime time.Time
+	}
+	var files []found
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !validKey(d.Name()) {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, found{key: d.Name(), size: info.Size(), mtime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения каталога кеша: %w", err)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].mtime.Before(files[j].mtime) })
+
+	// 2. Строим индекс и сразу ужимаем кеш до лимита (лимит мог уменьшиться)
+	for _, f := range files {
+		c.items[f.key] = c.order.PushFront(&diskEntry{key: f.key, size: f.size})
+		c.stats.Bytes += f.size
+	}
+	c.evict()
+	return c, nil
+}
+
+// Get читает значение с диска и помечает его как последнее использованное.
+func (c *Disk) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		// файл удалён снаружи — забываем о нём
+		c.remove(el)
+		c.stats.Misses++
+		return nil, false
+	}
+	c.stats.Hits++
+	c.order.MoveToFront(el)
+	now := time.Now()
+	os.Chtimes(c.path(key), now, now) // порядок вытеснения после перезапуска
+	return data, true
+}
+
+// Put записывает значение во временный файл и атомарно переименовывает его.
+func (c *Disk) Put(key string, data []byte) {
+	size := int64(len(data))
+	if size > c.max/maxEntryShare || !validKey(key) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+
+	c.stats.Puts++
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*diskEntry)
+		c.stats.Bytes += size - e.size
+		e.size = size
+		c.order.MoveToFront(el)
+	} else {
+		c.items[key] = c.order.PushFront(&diskEntry{key: key, size: size})
+		c.stats.Bytes += size
+	}
+	c.evict()
+}
+
+// Stats возвращает счётчики кеша.
+func (c *Disk) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Entries = len(c.items)
+	return s
+}
+
+// evict удаляет самые старые файлы, пока кеш не уложится в лимит. Вызывается под mu.
+func (c *Disk) evict() {
+	for c.stats.Bytes > c.max {
+		el := c.order.Back()
+		os.Remove(c.path(el.Value.(*diskEntry).key))
+		c.remove(el)
+		c.stats.Evictions++
+	}
+}
+
+// remove убирает файл из индекса. Вызывается под mu.
+func (c *Disk) remove(el *list.Element) {
+	e := el.Value.(*diskEntry)
+	c.order.Remove(el)
+	delete(c.items, e.key)
+	c.stats.Bytes -= e.size
+}
+
+// path возвращает путь к файлу значения: файлы раскладываются по подкаталогам
+// из первых двух символов ключа, чтобы в одном каталоге не было слишком много файлов.
+func (c *Disk) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+// validKey проверяет, что ключ — шестнадцатеричный SHA-256 (см. Key) и годится как имя файла.
+func validKey(key string) bool {
+	if len(key) != 64 {
+		return false
+	}
+	for _, r := range key {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// LRU — кеш в памяти с ограничением по суммарному размеру значений.
+// При переполнении вытесняются давно не использованные значения.
+type LRU struct {
+	mu    sync.Mutex
+	max   int64
+	items map[string]*list.Element
+	order *list.List // в начале — последние использованные
+	stats Stats
+}
+
+// lruEntry — значение в списке LRU.
+type lruEntry struct {
+	key  string
+	data []byte
+}
+
+// NewLRU создаёт кеш в памяти размером не больше maxBytes.
+func NewLRU(maxBytes int64) *LRU {
+	return &LRU{
+		max:   maxBytes,
+		items: map[string]*list.Element{},
+		order: list.New(),
+		stats: Stats{Backend: "memory", MaxBytes: maxBytes},
+	}
+}
+
+// Get возвращает значение и помечает его как последнее использованное.
+func (c *LRU) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	c.stats.Hits++
+	c.order.MoveToFront(el)
+	return el.Value.(*lruEntry).data, true
+}
+
+// Put сохраняет значение и вытесняет старые, пока не уложится в лимит.
+func (c *LRU) Put(key string, data []byte) {
+	size := int64(len(data))
+	if size > c.max/maxEntryShare {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Puts++
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*lruEntry)
+		c.stats.Bytes += size - int64(len(e.data))
+		e.data = data
+		c.order.MoveToFront(el)
+	} else {
+		c.items[key] = c.order.PushFront(&lruEntry{key: key, data: data})
+		c.stats.Bytes += size
+	}
+	for c.stats.Bytes > c.max {
+		el := c.order.Back()
+		e := el.Value.(*lruEntry)
+		c.order.Remove(el)
+		delete(c.items, e.key)
+		c.stats.Bytes -= int64(len(e.data))
+		c.stats.Evictions++
+	}
+}
+
+// Stats возвращает счётчики кеша.
+func (c *LRU) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Entries = len(c.items)
+	return s
+}
@@ -0,0 +1,72 @@
+package cache
+
+import "sync"
+
+// Tiered — многоуровневый кеш: уровни перебираются по порядку (обычно память, затем диск),
+// найденное на нижнем уровне копируется на верхние, запись идёт во все уровни.
+type Tiered struct {
+	levels []Cache
+
+	mu     sync.Mutex
+	hits   int64
+	misses int64
+}
+
+// NewTiered объединяет уровни кеша; первым передаётся самый быстрый.
+func NewTiered(levels ...Cache) *Tiered {
+	return &Tiered{levels: levels}
+}
+
+// Get ищет значение по уровням и поднимает найденное на верхние уровни.
+func (t *Tiered) Get(key string) ([]byte, bool) {
+	for i, c := range t.levels {
+		data, ok := c.Get(key)
+		if !ok {
+			continue
+		}
+		for _, upper := range t.levels[:i] {
+			upper.Put(key, data)
+		}
+		t.count(true)
+		return data, true
+	}
+	t.count(false)
+	return nil, false
+}
+
+// Put записывает значение во все уровни.
+func (t *Tiered) Put(key string, data []byte) {
+	for _, c := range t.levels {
+		c.Put(key, data)
+	}
+}
+
+// Stats возвращает итоговые попадания и промахи и счётчики каждого уровня.
+func (t *Tiered) Stats() Stats {
+	t.mu.Lock()
+	s := Stats{Backend: "tiered", Hits: t.hits, Misses: t.misses}
+	t.mu.Unlock()
+	for _, c := range t.levels {
+		ls := c.Stats()
+		s.Levels = append(s.Levels, ls)
+		s.Puts += ls.Puts
+		s.Evictions += ls.Evictions
+		s.Bytes += ls.Bytes
+		s.MaxBytes += ls.MaxBytes
+		if ls.Entries > s.Entries {
+			s.Entries = ls.Entries // уровни пересекаются, поэтому не суммируем
+		}
+	}
+	return s
+}
+
+// count учитывает итог поиска по всем уровням.
+func (t *Tiered) count(hit bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if hit {
+		t.hits++
+	} else {
+		t.misses++
+	}
+}
@@ -0,0 +1,444 @@
+// Package config собирает настройки сервера из флагов командной строки,
+// переменных окружения и YAML-файла.
+//
+// Приоритет: флаг > переменная окружения > файл > значение по умолчанию.
+// Каждый параметр имеет ключ в файле (database_url), флаг (-database-url)
+// и переменную окружения (DM_DATABASE_URL).
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config — итоговые настройки сервера.
+type Config struct {
+	DatabaseURL    string  `yaml:"database_url"`     // строка подключения к PostgreSQL
+	Addr           string  `yaml:"addr"`             // адрес HTTP-сервера
+	StaticDir      string  `yaml:"static_dir"`       // каталог статики вместо встроенной (для разработки)
+	PaletteMinDist float64 `yaml:"palette_min_dist"` // порог отбора различимых цветов палитры (Lab)
+	RareColorMin   int     `yaml:"rare_color_min"`   // цвета с меньшим числом алмазов заменяются
+	DrillSizeMM    float64 `yaml:"drill_size_mm"`    // размер одного алмаза в мм
+	InventoryMode  string  `yaml:"inventory_mode"`   // учёт складских остатков: ignore, avoid или cap
+	StockFile      string  `yaml:"stock_file"`       // CSV с остатками (dmc_code,quantity) поверх данных БД
+
+	ReadTimeout     time.Duration `yaml:"read_timeout"`     // чтение запроса вместе с файлом
+	WriteTimeout    time.Duration `yaml:"write_timeout"`    // генерация и отправка ответа
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`     // простой keep-alive соединения
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"` // ожидание текущих генераций при остановке
+
+	CacheMemoryMB int    `yaml:"cache_memory_mb"` // размер кеша результатов в памяти, 0 — без кеша в памяти
+	CacheDir      string `yaml:"cache_dir"`       // каталог дискового кеша, пусто — без дискового кеша
+	CacheDiskMB   int    `yaml:"cache_disk_mb"`   // размер дискового кеша
+
+	MigrateOnStart bool   `yaml:"migrate_on_start"` // применять миграции БД при запуске
+	PaletteSeed    string `yaml:"palette_seed"`     // CSV с палитрой для пустой таблицы palette; пусто — встроенный набор DMC
+	AdminToken     string `yaml:"admin_token"`      // токен админ-API палитры, пусто — админ-API выключен
+
+	PaletteReloadInterval time.Duration `yaml:"palette_reload_interval"` // период перечитывания палитры из БД, 0 — только по SIGHUP и админ-API
+
+	KitBagSize        int     `yaml:"kit_bag_size"`        // алмазов в одном пакетике
+	KitSparePercent   float64 `yaml:"kit_spare_percent"`   // запас алмазов сверх схемы, %
+	KitBagPrice       float64 `yaml:"kit_bag_price"`       // цена пакетика, если в палитре цена не задана
+	KitCanvasPriceM2  float64 `yaml:"kit_canvas_price_m2"` // цена холста за м²
+	KitToolsPrice     float64 `yaml:"kit_tools_price"`     // цена набора инструментов
+	KitPackagingPrice float64 `yaml:"kit_packaging_price"` // цена упаковки
+	KitCurrency       string  `yaml:"kit_currency"`        // валюта цен
+
+	sources map[string]string // ключ -> откуда взято значение
+}
+
+// envPrefix — префикс переменных окружения.
+const envPrefix = "DM_"
+
+// param описывает один параметр конфигурации.
+type param struct {
+	key    string
+	usage  string
+	redact func(v string) string // скрывает секрет при печати конфигурации; nil — не секрет
+	get    func(c *Config) string
+	set    func(c *Config, v string) error
+}
+
+// params — все параметры конфигурации в порядке вывода.
+var params = []param{
+	{
+		key: "database_url", usage: "строка подключения к PostgreSQL", redact: RedactDSN,
+		get: func(c *Config) string { return c.DatabaseURL },
+		set: func(c *Config, v string) error { c.DatabaseURL = v; return nil },
+	},
+	{
+		key: "addr", usage: "адрес HTTP-сервера",
+		get: func(c *Config) string { return c.Addr },
+		set: func(c *Config, v string) error { c.Addr = v; return nil },
+	},
+	{
+		key: "static_dir", usage: "каталог со статикой вместо встроенной (для разработки)",
+		get: func(c *Config) string { return c.StaticDir },
+		set: func(c *Config, v string) error { c.StaticDir = v; return nil },
+	},
+	{
+		key: "palette_min_dist", usage: "минимальное расстояние в Lab между цветами палитры",
+		get: func(c *Config) string { return strconv.FormatFloat(c.PaletteMinDist, 'g', -1, 64) },
+		set: func(c *Config, v string) error { return parseFloat(v, &c.PaletteMinDist) },
+	},
+	{
+		key: "rare_color_min", usage: "минимальное число алмазов цвета, иначе цвет заменяется",
+		get: func(c *Config) string { return strconv.Itoa(c.RareColorMin) },
+		set: func(c *Config, v string) error { return parseInt(v, &c.RareColorMin) },
+	},
+	{
+		key: "drill_size_mm", usage: "размер одного алмаза в мм",
+		get: func(c *Config) string { return strconv.FormatFloat(c.DrillSizeMM, 'g', -1, 64) },
+		set: func(c *Config, v string) error { return parseFloat(v, &c.DrillSizeMM) },
+	},
+	{
+		key: "inventory_mode", usage: "учёт складских остатков при подборе цветов: ignore, avoid или cap",
+		get: func(c *Config) string { return c.InventoryMode },
+		set: func(c *Config, v string) error { c.InventoryMode = v; return nil },
+	},
+	{
+		key: "stock_file", usage: "CSV со складскими остатками (dmc_code,quantity), перечитывается вместе с палитрой",
+		get: func(c *Config) string { return c.StockFile },
+		set: func(c *Config, v string) error { c.StockFile = v; return nil },
+	},
+	{
+		key: "kit_bag_size", usage: "число алмазов в одном пакетике набора",
+		get: func(c *Config) string { return strconv.Itoa(c.KitBagSize) },
+		set: func(c *Config, v string) error { return parseInt(v, &c.KitBagSize) },
+	},
+	{
+		key: "kit_spare_percent", usage: "запас алмазов сверх схемы в процентах",
+		get: func(c *Config) string { return strconv.FormatFloat(c.KitSparePercent, 'g', -1, 64) },
+		set: func(c *Config, v string) error { return parseFloat(v, &c.KitSparePercent) },
+	},
+	{
+		key: "kit_bag_price", usage: "цена пакетика для цветов без цены в палитре",
+		get: func(c *Config) string { return strconv.FormatFloat(c.KitBagPrice, 'g', -1, 64) },
+		set: func(c *Config, v string) error { return parseFloat(v, &c.KitBagPrice) },
+	},
+	{
+		key: "kit_canvas_price_m2", usage: "цена холста за квадратный метр",
+		get: func(c *Config) string { return strconv.FormatFloat(c.KitCanvasPriceM2, 'g', -1, 64) },
+		set: func(c *Config, v string) error { return parseFloat(v, &c.KitCanvasPriceM2) },
+	},
+	{
+		key: "kit_tools_price", usage: "цена набора инструментов (ручка, воск, лоток)",
+		get: func(c *Config) string { return strconv.FormatFloat(c.KitToolsPrice, 'g', -1, 64) },
+		set: func(c *Config, v string) error { return parseFloat(v, &c.KitToolsPrice) },
+	},
+	{
+		key: "kit_packaging_price", usage: "цена упаковки набора",
+		get: func(c *Config) string { return strconv.FormatFloat(c.KitPackagingPrice, 'g', -1, 64) },
+		set: func(c *Config, v string) error { return parseFloat(v, &c.KitPackagingPrice) },
+	},
+	{
+		key: "kit_currency", usage: "валюта цен комплектации",
+		get: func(c *Config) string { return c.KitCurrency },
+		set: func(c *Config, v string) error { c.KitCurrency = v; return nil },
+	},
+	{
+		key: "read_timeout", usage: "таймаут чтения запроса",
+		get: func(c *Config) string { return c.ReadTimeout.String() },
+		set: func(c *Config, v string) error { return parseDuration(v, &c.ReadTimeout) },
+	},
+	{
+		key: "write_timeout", usage: "таймаут генерации и записи ответа",
+		get: func(c *Config) string { return c.WriteTimeout.String() },
+		set: func(c *Config, v string) error { return parseDuration(v, &c.WriteTimeout) },
+	},
+	{
+		key: "idle_timeout", usage: "таймаут простоя keep-alive соединения",
+		get: func(c *Config) string { return c.IdleTimeout.String() },
+		set: func(c *Config, v string) error { return parseDuration(v, &c.IdleTimeout) },
+	},
+	{
+		key: "shutdown_timeout", usage: "сколько ждать завершения текущих запросов при остановке",
+		get: func(c *Config) string { return c.ShutdownTimeout.String() },
+		set: func(c *Config, v string) error { return parseDuration(v, &c.ShutdownTimeout) },
+	},
+	{
+		key: "cache_memory_mb", usage: "размер кеша результатов в памяти, МБ (0 — выключен)",
+		get: func(c *Config) string { return strconv.Itoa(c.CacheMemoryMB) },
+		set: func(c *Config, v string) error { return parseInt(v, &c.CacheMemoryMB) },
+	},
+	{
+		key: "cache_dir", usage: "каталог дискового кеша результатов (пусто — выключен)",
+		get: func(c *Config) string { return c.CacheDir },
+		set: func(c *Config, v string) error { c.CacheDir = v; return nil },
+	},
+	{
+		key: "cache_disk_mb", usage: "размер дискового кеша результатов, МБ",
+		get: func(c *Config) string { return strconv.Itoa(c.CacheDiskMB) },
+		set: func(c *Config, v string) error { return parseInt(v, &c.CacheDiskMB) },
+	},
+	{
+		key: "migrate_on_start", usage: "применять миграции БД при запуске сервера",
+		get: func(c *Config) string { return strconv.FormatBool(c.MigrateOnStart) },
+		set: func(c *Config, v string) error { return parseBool(v, &c.MigrateOnStart) },
+	},
+	{
+		key: "palette_seed", usage: "CSV с палитрой (dmc_code,name,r,g,b) для заполнения пустой таблицы palette; по умолчанию — встроенный набор DMC",
+		get: func(c *Config) string { return c.PaletteSeed },
+		set: func(c *Config, v string) error { c.PaletteSeed = v; return nil },
+	},
+	{
+		key: "admin_token", usage: "токен для админ-API палитры (Authorization: Bearer), пусто — выключено", redact: redactSecret,
+		get: func(c *Config) string { return c.AdminToken },
+		set: func(c *Config, v string) error { c.AdminToken = v; return nil },
+	},
+	{
+		key: "palette_reload_interval", usage: "как часто перечитывать палитру из БД (0 — только по SIGHUP и через админ-API)",
+		get: func(c *Config) string { return c.PaletteReloadInterval.String() },
+		set: func(c *Config, v string) error { return parseDuration(v, &c.PaletteReloadInterval) },
+	},
+}
+
+// Default возвращает настройки по умолчанию.
+func Default() Config {
+	return Config{
+		DatabaseURL:    "postgres://postgres@localhost:5432/diamond_mosaic?sslmode=disable",
+		Addr:           ":8080",
+		PaletteMinDist: 0.11,
+		RareColorMin:   30,
+		DrillSizeMM:    2.5,
+		InventoryMode:  "ignore",
+
+		KitBagSize:      200,
+		KitSparePercent: 10,
+		KitCurrency:     "RUB",
+
+		ReadTimeout:     time.Minute,
+		WriteTimeout:    3 * time.Minute,
+		IdleTimeout:     2 * time.Minute,
+		ShutdownTimeout: 3 * time.Minute,
+
+		CacheMemoryMB: 256,
+		CacheDiskMB:   2048,
+
+		MigrateOnStart: true,
+	}
+}
+
+// Load собирает конфигурацию из аргументов командной строки args (без имени программы),
+// окружения и файла, указанного флагом -config или переменной DM_CONFIG.
+// На -h и -help печатает справку по флагам и возвращает flag.ErrHelp.
+func Load(args []string) (Config, error) {
+	cfg := Default()
+	cfg.sources = map[string]string{}
+
+	// 1. Регистрируем флаги: по одному на параметр плюс -config
+	fs := flag.NewFlagSet("diamond-mosaic", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv(envPrefix+"CONFIG"), "путь к YAML-файлу конфигурации (или "+envPrefix+"CONFIG)")
+	flagValues := map[string]*string{}
+	for _, p := range params {
+		flagValues[p.key] = fs.String(flagName(p.key), p.get(&cfg), p.usage+" (или "+envName(p.key)+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	// 2. Файл
+	if *configPath != "" {
+		if err := cfg.loadFile(*configPath); err != nil {
+			return Config{}, err
+		}
+	}
+
+	// 3. Переменные окружения
+	for _, p := range params {
+		if v, ok := os.LookupEnv(envName(p.key)); ok {
+			if err := p.set(&cfg, v); err != nil {
+				return Config{}, fmt.Errorf("переменная %s: %w", envName(p.key), err)
+			}
+			cfg.sources[p.key] = "env"
+		}
+	}
+
+	// 4. Флаги, заданные явно
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, p := range params {
+			if flagName(p.key) != f.Name || flagErr != nil {
+				continue
+			}
+			if err := p.set(&cfg, *flagValues[p.key]); err != nil {
+				flagErr = fmt.Errorf("флаг -%s: %w", f.Name, err)
+			}
+			cfg.sources[p.key] = "flag"
+		}
+	})
+	if flagErr != nil {
+		return Config{}, flagErr
+	}
+
+	if err := cfg.validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// loadFile читает YAML-файл поверх текущих значений.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения файла конфигурации: %w", err)
+	}
+
+	// Разбираем в map, чтобы знать, какие ключи реально заданы в файле
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("ошибка разбора файла конфигурации %s: %w", path, err)
+	}
+	for key, v := range raw {
+		p, ok := lookupParam(key)
+		if !ok {
+			return fmt.Errorf("файл конфигурации %s: неизвестный параметр %q", path, key)
+		}
+		if err := p.set(c, fmt.Sprint(v)); err != nil {
+			return fmt.Errorf("файл конфигурации %s, параметр %s: %w", path, key, err)
+		}
+		c.sources[key] = "file"
+	}
+	return nil
+}
+
+// validate проверяет допустимость значений.
+func (c *Config) validate() error {
+	switch {
+	case c.DatabaseURL == "":
+		return fmt.Errorf("не задана строка подключения к БД (%s)", envName("database_url"))
+	case c.Addr == "":
+		return fmt.Errorf("не задан адрес HTTP-сервера")
+	case c.PaletteMinDist < 0:
+		return fmt.Errorf("palette_min_dist не может быть отрицательным")
+	case c.RareColorMin < 0:
+		return fmt.Errorf("rare_color_min не может быть отрицательным")
+	case c.DrillSizeMM <= 0:
+		return fmt.Errorf("drill_size_mm должен быть больше нуля")
+	case c.ReadTimeout <= 0 || c.WriteTimeout <= 0 || c.IdleTimeout <= 0 || c.ShutdownTimeout <= 0:
+		return fmt.Errorf("таймауты должны быть больше нуля")
+	case c.InventoryMode != "ignore" && c.InventoryMode != "avoid" && c.InventoryMode != "cap":
+		return fmt.Errorf("inventory_mode должен быть ignore, avoid или cap")
+	case c.PaletteReloadInterval < 0:
+		return fmt.Errorf("palette_reload_interval не может быть отрицательным")
+	case c.KitBagSize <= 0:
+		return fmt.Errorf("kit_bag_size должен быть больше нуля")
+	case c.KitSparePercent < 0 || c.KitBagPrice < 0 || c.KitCanvasPriceM2 < 0 || c.KitToolsPrice < 0 || c.KitPackagingPrice < 0:
+		return fmt.Errorf("запас и цены комплектации не могут быть отрицательными")
+	case c.CacheMemoryMB < 0 || c.CacheDiskMB < 0:
+		return fmt.Errorf("размеры кеша не могут быть отрицательными")
+	}
+	return nil
+}
+
+// Print выводит итоговую конфигурацию с указанием источника каждого значения.
+// Секреты скрываются: в строке подключения — пароль, остальные — целиком.
+func (c Config) Print(w io.Writer) {
+	fmt.Fprintln(w, "Конфигурация:")
+	for _, p := range params {
+		v := p.get(&c)
+		if p.redact != nil {
+			v = p.redact(v)
+		}
+		src := c.sources[p.key]
+		if src == "" {
+			src = "default"
+		}
+		fmt.Fprintf(w, "  %-18s = %q (%s)\n", p.key, v, src)
+	}
+}
+
+// redactSecret скрывает секрет целиком; пустое значение остаётся пустым, чтобы было видно,
+// что секрет не задан.
+func redactSecret(v string) string {
+	if v == "" {
+		return ""
+	}
+	return "xxxxx"
+}
+
+// passwordKV находит пароль в DSN формата "key=value".
+var passwordKV = regexp.MustCompile(`(?i)(password\s*=\s*)('[^']*'|\S+)`)
+
+// RedactDSN скрывает пароль в строке подключения к PostgreSQL
+// (как в URL-формате, так и в формате "key=value").
+func RedactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		if _, has := u.User.Password(); has {
+			u.User = url.UserPassword(u.User.Username(), "xxxxx")
+		}
+		q := u.Query()
+		if q.Get("password") != "" {
+			q.Set("password", "xxxxx")
+			u.RawQuery = q.Encode()
+		}
+		return u.String()
+	}
+	return passwordKV.ReplaceAllString(dsn, "${1}xxxxx")
+}
+
+// lookupParam ищет параметр по ключу файла.
+func lookupParam(key string) (param, bool) {
+	for _, p := range params {
+		if p.key == key {
+			return p, true
+		}
+	}
+	return param{}, false
+}
+
+// flagName переводит ключ файла в имя флага: database_url -> database-url.
+func flagName(key string) string {
+	return strings.ReplaceAll(key, "_", "-")
+}
+
+// envName переводит ключ файла в имя переменной окружения: database_url -> DM_DATABASE_URL.
+func envName(key string) string {
+	return envPrefix + strings.ToUpper(key)
+}
+
+func parseFloat(v string, dst *float64) error {
+	f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil {
+		return fmt.Errorf("ожидается число: %q", v)
+	}
+	*dst = f
+	return nil
+}
+
+func parseDuration(v string, dst *time.Duration) error {
+	d, err := time.ParseDuration(strings.TrimSpace(v))
+	if err != nil {
+		return fmt.Errorf("ожидается длительность вида 30s, 2m: %q", v)
+	}
+	*dst = d
+	return nil
+}
+
+func parseBool(v string, dst *bool) error {
+	b, err := strconv.ParseBool(strings.TrimSpace(v))
+	if err != nil {
+		return fmt.Errorf("ожидается true или false: %q", v)
+	}
+	*dst = b
+	return nil
+}
+
+func parseInt(v string, dst *int) error {
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return fmt.Errorf("ожидается целое число: %q", v)
+	}
+	*dst = n
+	return nil
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile записывает YAML-файл конфигурации во временный каталог.
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, "addr: \":7000\"\nrare_color_min: 10\ndrill_size_mm: 2.8\nread_timeout: 5s\n")
+	t.Setenv("DM_RARE_COLOR_MIN", "20")
+	t.Setenv("DM_DRILL_SIZE_MM", "2.7")
+
+	cfg, err := Load([]string{"-config", path, "-drill-size-mm", "3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key    string
+		got    interface{}
+		want   interface{}
+		source string
+	}{
+		{"drill_size_mm", cfg.DrillSizeMM, 3.0, "flag"},
+		{"rare_color_min", cfg.RareColorMin, 20, "env"},
+		{"addr", cfg.Addr, ":7000", "file"},
+		{"read_timeout", cfg.ReadTimeout, 5 * time.Second, "file"},
+		{"palette_min_dist", cfg.PaletteMinDist, Default().PaletteMinDist, ""},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, ожидалось %v", tt.key, tt.got, tt.want)
+		}
+		if src := cfg.sources[tt.key]; src != tt.source {
+			t.Errorf("%s: источник %q, ожидался %q", tt.key, src, tt.source)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		file string
+		env  map[string]string
+	}{
+		{"неизвестный ключ файла", nil, "no_such_key: 1\n", nil},
+		{"число в файле", nil, "rare_color_min: много\n", nil},
+		{"переменная окружения", nil, "", map[string]string{"DM_READ_TIMEOUT": "долго"}},
+		{"флаг", []string{"-cache-memory-mb", "x"}, "", nil},
+		{"неизвестный флаг", []string{"-no-such-flag"}, "", nil},
+		{"проверка значений", []string{"-inventory-mode", "always"}, "", nil},
+		{"отрицательный кеш", []string{"-cache-disk-mb", "-1"}, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeFile(t, tt.file)}, args...)
+			}
+			if _, err := Load(args); err == nil {
+				t.Error("ожидалась ошибка")
+			}
+		})
+	}
+}
+
+func TestLoadHelp(t *testing.T) {
+	stderr := os.Stderr
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	os.Stderr = devNull // справка по флагам печатается в stderr
+	defer func() { os.Stderr = stderr }()
+
+	if _, err := Load([]string{"-h"}); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("Load(-h) = %v, ожидалось flag.ErrHelp", err)
+	}
+}
+
+func TestRedactDSN(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"postgres://user:secret@db:5432/dm?sslmode=disable", "postgres://user:xxxxx@db:5432/dm?sslmode=disable"},
+		{"postgres://user@db/dm", "postgres://user@db/dm"},
+		{"postgres://db/dm?password=secret", "postgres://db/dm?password=xxxxx"},
+		{"host=db user=u password=secret dbname=dm", "host=db user=u password=xxxxx dbname=dm"},
+		{"host=db password = 'my secret' dbname=dm", "host=db password = xxxxx dbname=dm"},
+	}
+	for _, tt := range tests {
+		if got := RedactDSN(tt.in); got != tt.want {
+			t.Errorf("RedactDSN(%q) = %q, ожидалось %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	cfg, err := Load([]string{"-database-url", "postgres://u:dbsecret@db/dm", "-admin-token", "tokensecret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	cfg.Print(&buf)
+	out := buf.String()
+	for _, secret := range []string{"dbsecret", "tokensecret"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("секрет %q попал в вывод конфигурации:\n%s", secret, out)
+		}
+	}
+	for _, want := range []string{`admin_token        = "xxxxx" (flag)`, `"postgres://u:xxxxx@db/dm" (flag)`, `addr               = ":8080" (default)`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("в выводе нет %q:\n%s", want, out)
+		}
+	}
+
+	// Незаданный токен остаётся пустым
+	buf.Reset()
+	Default().Print(&buf)
+	if !strings.Contains(buf.String(), `admin_token        = ""`) {
+		t.Errorf("пустой токен выведен не пустым:\n%s", buf.String())
+	}
+}
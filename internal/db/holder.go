@@ -0,0 +1,87 @@
+package db
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PaletteSnapshot — неизменяемое состояние палитры на момент загрузки.
+// Запрос берёт снимок один раз и работает с ним до конца, даже если палитру
+// в это время перезагрузили. Colors и All изменять нельзя.
+type PaletteSnapshot struct {
+	Colors   []PaletteColor // рабочая палитра: только достаточно различимые цвета
+	All      []PaletteColor // все загруженные цвета до FilterPalette — по ним узнаются коды DMC
+	Version  string         // PaletteVersion(Colors), посчитанная один раз при загрузке
+	LoadedAt time.Time
+
+	allVersion string // PaletteVersion(All): изменение отброшенного цвета тоже обновляет снимок
+}
+
+// newPaletteSnapshot копирует colors, чтобы снимок не зависел от исходного среза,
+// и отбирает из них рабочую палитру с порогом minDist.
+func newPaletteSnapshot(colors []PaletteColor, minDist float64) *PaletteSnapshot {
+	own := make([]PaletteColor, len(colors))
+	copy(own, colors)
+	filtered := FilterPalette(own, minDist)
+	return &PaletteSnapshot{
+		Colors:     filtered,
+		All:        own,
+		Version:    PaletteVersion(filtered),
+		LoadedAt:   time.Now(),
+		allVersion: PaletteVersion(own),
+	}
+}
+
+// Len возвращает число цветов в снимке.
+func (s *PaletteSnapshot) Len() int {
+	return len(s.Colors)
+}
+
+// PaletteHolder хранит текущий снимок палитры. Чтение (Load) не блокируется и безопасно
+// из любых горутин; замена снимка атомарна, перезагрузки выполняются по очереди.
+type PaletteHolder struct {
+	current atomic.Value // *PaletteSnapshot
+	mu      sync.Mutex   // упорядочивает Store и Reload
+	minDist float64      // порог FilterPalette для рабочей палитры
+}
+
+// NewPaletteHolder создаёт хранилище с начальной палитрой colors (может быть пустой).
+// Рабочая палитра снимков — цвета, различающиеся в Lab не меньше чем на minDist; 0 — все цвета.
+func NewPaletteHolder(colors []PaletteColor, minDist float64) *PaletteHolder {
+	h := &PaletteHolder{minDist: minDist}
+	h.current.Store(newPaletteSnapshot(colors, minDist))
+	return h
+}
+
+// Load возвращает текущий снимок палитры.
+func (h *PaletteHolder) Load() *PaletteSnapshot {
+	return h.current.Load().(*PaletteSnapshot)
+}
+
+// Store заменяет палитру и возвращает новый снимок.
+func (h *PaletteHolder) Store(colors []PaletteColor) *PaletteSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	snap := newPaletteSnapshot(colors, h.minDist)
+	h.current.Store(snap)
+	return snap
+}
+
+// Reload загружает палитру через load и заменяет текущую, если её версия изменилась.
+// Одновременные вызовы выполняются по очереди, так что более старая загрузка
+// не перезапишет более новую. При ошибке остаётся прежняя палитра.
+func (h *PaletteHolder) Reload(load func() ([]PaletteColor, error)) (snap *PaletteSnapshot, changed bool, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	colors, err := load()
+	if err != nil {
+		return h.Load(), false, err
+	}
+	snap = newPaletteSnapshot(colors, h.minDist)
+	if snap.Version == h.Load().Version && snap.allVersion == h.Load().allVersion {
+		return h.Load(), false, nil
+	}
+	h.current.Store(snap)
+	return snap, true, nil
+}
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// migrationFiles — SQL-миграции, встроенные в бинарник.
+// Имя файла: NNNN_описание.sql, где NNNN — номер версии схемы.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLock — ключ advisory-блокировки PostgreSQL, чтобы несколько
+// одновременно запущенных серверов не применяли миграции параллельно.
+const migrationLock = 0x646d6d67 // "dmmg"
+
+// Migration — одна миграция схемы БД.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// Migrations возвращает встроенные миграции по возрастанию версии.
+func Migrations() ([]Migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+	var list []Migration
+	seen := map[int]string{}
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".sql")
+		num, title, ok := strings.Cut(name, "_")
+		version, err := strconv.Atoi(num)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("некорректное имя миграции %s", e.Name())
+		}
+		if prev, dup := seen[version]; dup {
+			return nil, fmt.Errorf("миграции %s и %s имеют одну версию", prev, e.Name())
+		}
+		seen[version] = e.Name()
+		data, err := migrationFiles.ReadFile(path.Join("migrations", e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, Migration{Version: version, Name: title, SQL: string(data)})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// Migrate применяет к БД ещё не применённые миграции, каждую в своей транзакции,
+// и записывает их версии в таблицу schema_migrations. Возвращает применённые миграции.
+func Migrate(ctx context.Context, db *sql.DB) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. Берём блокировку на отдельном соединении: она держится до конца работы
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка подключения к БД: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLock); err != nil {
+		return nil, fmt.Errorf("ошибка блокировки миграций: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLock)
+
+	// 2. Таблица версий
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`); err != nil {
+		return nil, fmt.Errorf("ошибка создания таблицы schema_migrations: %w", err)
+	}
+	current, err := schemaVersion(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Применяем новые миграции по порядку
+	var applied []Migration
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		if err := applyMigration(ctx, conn, m); err != nil {
+			return applied, err
+		}
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
+
+// applyMigration выполняет миграцию и записывает её версию в одной транзакции.
+func applyMigration(ctx context.Context, conn *sql.Conn, m Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+		return fmt.Errorf("миграция %04d_%s: %w", m.Version, m.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name); err != nil {
+		return fmt.Errorf("миграция %04d_%s: ошибка записи версии: %w", m.Version, m.Name, err)
+	}
+	return tx.Commit()
+}
+
+// SchemaVersion возвращает текущую версию схемы БД (0, если миграции не применялись).
+func SchemaVersion(ctx context.Context, db *sql.DB) (int, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	var exists bool
+	if err := conn.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return 0, fmt.Errorf("ошибка чтения версии схемы: %w", err)
+	}
+	if !exists {
+		return 0, nil
+	}
+	return schemaVersion(ctx, conn)
+}
+
+// schemaVersion читает максимальную применённую версию из schema_migrations.
+func schemaVersion(ctx context.Context, conn *sql.Conn) (int, error) {
+	var version int
+	if err := conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("ошибка чтения версии схемы: %w", err)
+	}
+	return version, nil
+}
@@ -0,0 +1,10 @@
+-- Палитра цветов DMC. В старых базах таблица могла быть создана вручную без ключа,
+-- поэтому уникальность кода обеспечивается отдельным индексом.
+CREATE TABLE IF NOT EXISTS palette (
+	dmc_code TEXT NOT NULL,
+	name     TEXT NOT NULL,
+	r        SMALLINT NOT NULL CHECK (r BETWEEN 0 AND 255),
+	g        SMALLINT NOT NULL CHECK (g BETWEEN 0 AND 255),
+	b        SMALLINT NOT NULL CHECK (b BETWEEN 0 AND 255)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS palette_dmc_code_idx ON palette (dmc_code);
@@ -0,0 +1,18 @@
+-- Сохранённые схемы (см. SchemeRepository).
+CREATE TABLE IF NOT EXISTS schemes (
+	id              TEXT PRIMARY KEY,
+	created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+	accessed_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+	width_cm        INTEGER NOT NULL,
+	height_cm       INTEGER NOT NULL,
+	grid_width      INTEGER NOT NULL,
+	grid_height     INTEGER NOT NULL,
+	colors          INTEGER NOT NULL,
+	drills          INTEGER NOT NULL,
+	source_sha256   TEXT NOT NULL DEFAULT '',
+	palette_version TEXT NOT NULL DEFAULT '',
+	params          JSONB NOT NULL,
+	legend          JSONB NOT NULL,
+	grid            BYTEA NOT NULL
+);
+CREATE INDEX IF NOT EXISTS schemes_created_at_idx ON schemes (created_at DESC);
@@ -0,0 +1,14 @@
+-- Управление палитрой через админ-API (см. PaletteService).
+ALTER TABLE palette ADD COLUMN IF NOT EXISTS enabled    BOOLEAN NOT NULL DEFAULT true;
+ALTER TABLE palette ADD COLUMN IF NOT EXISTS position   INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE palette ADD COLUMN IF NOT EXISTS symbol     TEXT NOT NULL DEFAULT '';
+ALTER TABLE palette ADD COLUMN IF NOT EXISTS in_stock   BOOLEAN NOT NULL DEFAULT true;
+ALTER TABLE palette ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+-- Исходный порядок — по коду
+UPDATE palette p SET position = o.n
+FROM (SELECT dmc_code, row_number() OVER (ORDER BY dmc_code) AS n FROM palette) o
+WHERE p.dmc_code = o.dmc_code;
+
+-- Закреплённый символ не может принадлежать двум цветам
+CREATE UNIQUE INDEX IF NOT EXISTS palette_symbol_idx ON palette (symbol) WHERE symbol <> '';
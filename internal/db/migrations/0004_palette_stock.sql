@@ -0,0 +1,2 @@
+-- Остаток цвета на складе в алмазах; NULL — остаток не учитывается.
+ALTER TABLE palette ADD COLUMN IF NOT EXISTS stock INTEGER CHECK (stock IS NULL OR stock >= 0);
@@ -0,0 +1,2 @@
+-- Цена пакетика цвета для расчёта стоимости набора; NULL — цена по умолчанию из конфигурации.
+ALTER TABLE palette ADD COLUMN IF NOT EXISTS bag_price NUMERIC(10, 2) CHECK (bag_price IS NULL OR bag_price >= 0);
@@ -1,8 +1,12 @@
 package db
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/lucasb-eyer/go-colorful"
 	_ "github.com/lib/pq"
@@ -14,6 +18,24 @@ type PaletteColor struct {
 	Name    string			// Название цвета
 	Color   colorful.Color	// Цвет в RGB
 	Symbol  string			// Символ для схемы
+
+	PreferredSymbol string	// символ, который администратор закрепил за цветом (пусто — любой)
+	OutOfStock      bool	// цвета нет на складе
+	Stock           int	// остаток на складе в алмазах, 0 — остаток не учитывается
+	BagPrice        float64	// цена пакетика, 0 — цена по умолчанию
+}
+
+// Available возвращает, сколько алмазов цвета можно использовать: 0 — цвета нет на складе,
+// -1 — без ограничения.
+func (pc PaletteColor) Available() int {
+	switch {
+	case pc.OutOfStock:
+		return 0
+	case pc.Stock > 0:
+		return pc.Stock
+	default:
+		return -1
+	}
 }
 
 // LoadPalette подключается к базе данных и загружает палитру цветов из таблицы palette.
@@ -25,9 +47,15 @@ func LoadPalette(connStr string) ([]PaletteColor, error) {
 		return nil, fmt.Errorf("ошибка открытия БД: %w", err)
 	}
 	defer db.Close()
+	return LoadPaletteFrom(db)
+}
 
+// LoadPaletteFrom загружает палитру через уже открытый пул соединений.
+// Отключённые цвета пропускаются, порядок задаётся столбцом position.
+func LoadPaletteFrom(db *sql.DB) ([]PaletteColor, error) {
 	// 2. Делаем SELECT-запрос к таблице palette
-	rows, err := db.Query("SELECT dmc_code, name, r, g, b FROM palette")
+	rows, err := db.Query(`SELECT dmc_code, name, r, g, b, symbol, in_stock, stock, bag_price FROM palette
+		WHERE enabled ORDER BY position, dmc_code`)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка запроса к таблице palette: %w", err)
 	}
@@ -36,9 +64,12 @@ func LoadPalette(connStr string) ([]PaletteColor, error) {
 	// 3. Считываем строки и строим срез PaletteColor
 	var palette []PaletteColor
 	for rows.Next() {
-		var dmcCode, name string
+		var dmcCode, name, symbol string
 		var r, g, b int
-		if err := rows.Scan(&dmcCode, &name, &r, &g, &b); err != nil {
+		var inStock bool
+		var stock sql.NullInt64
+		var bagPrice sql.NullFloat64
+		if err := rows.Scan(&dmcCode, &name, &r, &g, &b, &symbol, &inStock, &stock, &bagPrice); err != nil {
 			return nil, fmt.Errorf("ошибка чтения строки: %w", err)
 		}
 		color := colorful.Color{
@@ -50,6 +81,12 @@ func LoadPalette(connStr string) ([]PaletteColor, error) {
 			DMCCode: dmcCode,
 			Name:    name,
 			Color:   color,
+
+			PreferredSymbol: symbol,
+			// нулевой остаток — то же, что «нет на складе»
+			OutOfStock: !inStock || (stock.Valid && stock.Int64 == 0),
+			Stock:      int(stock.Int64),
+			BagPrice:   bagPrice.Float64,
 		})
 	}
 	if err := rows.Err(); err != nil {
@@ -60,32 +97,72 @@ func LoadPalette(connStr string) ([]PaletteColor, error) {
 }
 
 // FilterPalette оставляет только "достаточно разные" цвета из исходной палитры.
-// minDist — минимальная дистанция между цветами в пространстве Lab.
+// minDist — минимальная дистанция между цветами в пространстве Lab. Из близких цветов остаётся
+// стоящий раньше, но цвета, которые есть на складе, отбираются прежде отсутствующих: иначе
+// отсутствующий цвет вытеснил бы свой близкий аналог со склада. Порядок цветов сохраняется.
 func FilterPalette(palette []PaletteColor, minDist float64) []PaletteColor {
-    var filtered []PaletteColor
+	keep := make([]bool, len(palette))
+	var kept []int
+
+	// 1. Перебираем цвета: сначала те, что есть на складе, затем остальные
+	for _, inStock := range []bool{true, false} {
+		for i, pc := range palette {
+			if (pc.Available() != 0) != inStock {
+				continue
+			}
+			tooClose := false
+			l1, a1, b1 := pc.Color.Lab()
 
-	// 1. Перебираем все цвета из палитры
-    for _, pc := range palette {
-        tooClose := false
-        l1, a1, b1 := pc.Color.Lab()
+			// 2. Проверяем, что этот цвет не слишком близок к уже отобранным
+			for _, j := range kept {
+				l2, a2, b2 := palette[j].Color.Lab()
+				dl := l1 - l2
+				da := a1 - a2
+				db_ := b1 - b2
+				dist := dl*dl + da*da + db_*db_
+				if dist < minDist*minDist {
+					tooClose = true
+					break
+				}
+			}
+			// 3. Если цвет уникальный по расстоянию — отбираем
+			if !tooClose {
+				keep[i] = true
+				kept = append(kept, i)
+			}
+		}
+	}
 
-		// 2. Проверяем, что этот цвет не слишком близок к уже отобранным
-        for _, fpc := range filtered {
-            l2, a2, b2 := fpc.Color.Lab()
-            dl := l1 - l2
-            da := a1 - a2
-            db_ := b1 - b2
-            dist := dl*dl + da*da + db_*db_
-            if dist < minDist*minDist {
-                tooClose = true
-                break
-            }
-        }
-		// 3. Если цвет уникальный по расстоянию — добавляем
-        if !tooClose {
-            filtered = append(filtered, pc)
-        }
-    }
-	// 4. Возвращаем отфильтрованный срез
-    return filtered
+	// 4. Возвращаем отобранные цвета в исходном порядке
+	var filtered []PaletteColor
+	for i, pc := range palette {
+		if keep[i] {
+			filtered = append(filtered, pc)
+		}
+	}
+	return filtered
+}
+
+// PaletteVersion возвращает короткий отпечаток палитры: он меняется при любом изменении
+// кодов, названий, цветов, закреплённых символов, остатков или цен и позволяет понять,
+// по какой палитре построена схема.
+func PaletteVersion(palette []PaletteColor) string {
+	lines := make([]string, 0, len(palette))
+	for _, pc := range palette {
+		r, g, b := pc.Color.RGB255()
+		line := fmt.Sprintf("%s\t%s\t%d\t%d\t%d", pc.DMCCode, pc.Name, r, g, b)
+		if pc.PreferredSymbol != "" {
+			line += "\t" + pc.PreferredSymbol // закреплённый символ меняет вид схемы
+		}
+		if avail := pc.Available(); avail >= 0 {
+			line += fmt.Sprintf("\tstock=%d", avail) // остатки меняют подбор цветов
+		}
+		if pc.BagPrice > 0 {
+			line += fmt.Sprintf("\tprice=%.2f", pc.BagPrice) // цены попадают в расчёт стоимости набора
+		}
+		lines = append(lines, line)
+	}
+	sort.Strings(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:8])
 }
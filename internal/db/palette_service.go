@@ -0,0 +1,278 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrConflict возвращается, если код DMC или закреплённый символ уже заняты другим цветом.
+var ErrConflict = errors.New("код или символ уже занят")
+
+// ErrInvalidColor возвращается, если данные цвета не прошли проверку.
+var ErrInvalidColor = errors.New("некорректные данные цвета")
+
+// PaletteEntry — цвет палитры со служебными полями управления (см. PaletteService).
+type PaletteEntry struct {
+	DMCCode   string    `json:"dmc_code"`
+	Name      string    `json:"name"`
+	RGB       [3]int    `json:"rgb"`
+	Enabled   bool      `json:"enabled"`  // участвует ли цвет в подборе
+	Position  int       `json:"position"` // порядок в палитре: при фильтрации раньше стоящие цвета важнее
+	Symbol    string    `json:"symbol"`   // закреплённый символ, пусто — назначается автоматически
+	InStock   bool      `json:"in_stock"`
+	Stock     *int      `json:"stock"`     // остаток в алмазах, null — не учитывается
+	BagPrice  *float64  `json:"bag_price"` // цена пакетика, null — цена по умолчанию
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PaletteUpdate — частичное изменение цвета: nil-поля не меняются.
+type PaletteUpdate struct {
+	Name     *string  `json:"name"`
+	RGB      *[3]int  `json:"rgb"`
+	Enabled  *bool    `json:"enabled"`
+	Symbol   *string  `json:"symbol"`
+	InStock  *bool    `json:"in_stock"`
+	Stock    *int     `json:"stock"`     // -1 — перестать учитывать остаток
+	BagPrice *float64 `json:"bag_price"` // -1 — вернуть цену по умолчанию
+}
+
+// PaletteService изменяет таблицу palette. После каждого успешного изменения
+// вызывается onChange — через него работающий сервер перечитывает палитру.
+type PaletteService struct {
+	db       *sql.DB
+	onChange func()
+}
+
+// NewPaletteService создаёт сервис управления палитрой; onChange может быть nil.
+func NewPaletteService(db *sql.DB, onChange func()) *PaletteService {
+	return &PaletteService{db: db, onChange: onChange}
+}
+
+const paletteEntryColumns = `dmc_code, name, r, g, b, enabled, position, symbol, in_stock, stock, bag_price, updated_at`
+
+// List возвращает все цвета палитры, включая отключённые, в порядке position.
+func (s *PaletteService) List(ctx context.Context) ([]PaletteEntry, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+paletteEntryColumns+` FROM palette ORDER BY position, dmc_code`)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса к таблице palette: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []PaletteEntry{}
+	for rows.Next() {
+		e, err := scanPaletteEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при итерации по строкам: %w", err)
+	}
+	return entries, nil
+}
+
+// Create добавляет цвет в конец палитры.
+func (s *PaletteService) Create(ctx context.Context, e PaletteEntry) (PaletteEntry, error) {
+	e.DMCCode, e.Name, e.Symbol = strings.TrimSpace(e.DMCCode), strings.TrimSpace(e.Name), strings.TrimSpace(e.Symbol)
+	if err := validatePaletteEntry(e); err != nil {
+		return PaletteEntry{}, err
+	}
+	row := s.db.QueryRowContext(ctx, `
+		INSERT INTO palette (dmc_code, name, r, g, b, enabled, symbol, in_stock, stock, bag_price, position)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, (SELECT COALESCE(MAX(position), 0) + 1 FROM palette))
+		RETURNING `+paletteEntryColumns,
+		e.DMCCode, e.Name, e.RGB[0], e.RGB[1], e.RGB[2], e.Enabled, e.Symbol, e.InStock,
+		stockValue(e.Stock), priceValue(e.BagPrice))
+	created, err := scanPaletteEntry(row)
+	if err != nil {
+		return PaletteEntry{}, wrapConflict(err)
+	}
+	s.changed()
+	return created, nil
+}
+
+// Update применяет частичное изменение к цвету с кодом code.
+func (s *PaletteService) Update(ctx context.Context, code string, u PaletteUpdate) (PaletteEntry, error) {
+	// 1. Собираем SET только из переданных полей
+	var sets []string
+	var args []interface{}
+	set := func(column string, v interface{}) {
+		args = append(args, v)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if u.Name != nil {
+		set("name", strings.TrimSpace(*u.Name))
+	}
+	if u.RGB != nil {
+		if err := validateRGB(*u.RGB); err != nil {
+			return PaletteEntry{}, err
+		}
+		set("r", u.RGB[0])
+		set("g", u.RGB[1])
+		set("b", u.RGB[2])
+	}
+	if u.Enabled != nil {
+		set("enabled", *u.Enabled)
+	}
+	if u.Symbol != nil {
+		set("symbol", strings.TrimSpace(*u.Symbol))
+	}
+	if u.InStock != nil {
+		set("in_stock", *u.InStock)
+	}
+	if u.Stock != nil {
+		if *u.Stock < -1 {
+			return PaletteEntry{}, fmt.Errorf("%w: остаток не может быть отрицательным", ErrInvalidColor)
+		}
+		set("stock", stockValue(u.Stock))
+	}
+	if u.BagPrice != nil {
+		if *u.BagPrice < 0 && *u.BagPrice != -1 {
+			return PaletteEntry{}, fmt.Errorf("%w: цена не может быть отрицательной", ErrInvalidColor)
+		}
+		set("bag_price", priceValue(u.BagPrice))
+	}
+	if len(sets) == 0 {
+		return PaletteEntry{}, fmt.Errorf("%w: нет полей для изменения", ErrInvalidColor)
+	}
+
+	// 2. Обновляем строку и возвращаем её новое состояние
+	args = append(args, code)
+	row := s.db.QueryRowContext(ctx, fmt.Sprintf(`
+		UPDATE palette SET %s, updated_at = now() WHERE dmc_code = $%d
+		RETURNING `+paletteEntryColumns, strings.Join(sets, ", "), len(args)), args...)
+	updated, err := scanPaletteEntry(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return PaletteEntry{}, ErrNotFound
+	}
+	if err != nil {
+		return PaletteEntry{}, wrapConflict(err)
+	}
+	s.changed()
+	return updated, nil
+}
+
+// Reorder ставит перечисленные цвета в начало палитры в указанном порядке;
+// остальные сохраняют взаимный порядок и идут следом.
+func (s *PaletteService) Reorder(ctx context.Context, codes []string) error {
+	if len(codes) == 0 {
+		return fmt.Errorf("%w: пустой список кодов", ErrInvalidColor)
+	}
+	seen := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		if seen[code] {
+			return fmt.Errorf("%w: код %s указан дважды", ErrInvalidColor, code)
+		}
+		seen[code] = true
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 1. Проверяем, что все коды существуют
+	var found int
+	if err := tx.QueryRowContext(ctx, `SELECT count(*) FROM palette WHERE dmc_code = ANY($1)`,
+		pq.Array(codes)).Scan(&found); err != nil {
+		return fmt.Errorf("ошибка проверки кодов: %w", err)
+	}
+	if found != len(codes) {
+		return ErrNotFound
+	}
+
+	// 2. Перенумеровываем: сначала переданные коды, затем остальные по прежнему порядку
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE palette p SET position = o.n, updated_at = now()
+		FROM (
+			SELECT dmc_code, row_number() OVER (
+				ORDER BY array_position($1::text[], dmc_code) NULLS LAST, position, dmc_code) AS n
+			FROM palette
+		) o
+		WHERE p.dmc_code = o.dmc_code AND p.position <> o.n`, pq.Array(codes)); err != nil {
+		return fmt.Errorf("ошибка изменения порядка палитры: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.changed()
+	return nil
+}
+
+func (s *PaletteService) changed() {
+	if s.onChange != nil {
+		s.onChange()
+	}
+}
+
+// scanPaletteEntry читает строку с колонками paletteEntryColumns.
+func scanPaletteEntry(row interface{ Scan(...interface{}) error }) (PaletteEntry, error) {
+	var e PaletteEntry
+	var stock sql.NullInt64
+	var bagPrice sql.NullFloat64
+	err := row.Scan(&e.DMCCode, &e.Name, &e.RGB[0], &e.RGB[1], &e.RGB[2],
+		&e.Enabled, &e.Position, &e.Symbol, &e.InStock, &stock, &bagPrice, &e.UpdatedAt)
+	if stock.Valid {
+		n := int(stock.Int64)
+		e.Stock = &n
+	}
+	if bagPrice.Valid {
+		e.BagPrice = &bagPrice.Float64
+	}
+	return e, err
+}
+
+// stockValue переводит остаток из API в значение столбца stock: nil и -1 — NULL.
+func stockValue(stock *int) interface{} {
+	if stock == nil || *stock < 0 {
+		return nil
+	}
+	return *stock
+}
+
+func validatePaletteEntry(e PaletteEntry) error {
+	if strings.TrimSpace(e.DMCCode) == "" {
+		return fmt.Errorf("%w: пустой код DMC", ErrInvalidColor)
+	}
+	if e.Stock != nil && *e.Stock < -1 {
+		return fmt.Errorf("%w: остаток не может быть отрицательным", ErrInvalidColor)
+	}
+	if e.BagPrice != nil && *e.BagPrice < 0 {
+		return fmt.Errorf("%w: цена не может быть отрицательной", ErrInvalidColor)
+	}
+	return validateRGB(e.RGB)
+}
+
+func validateRGB(rgb [3]int) error {
+	for i, v := range rgb {
+		if v < 0 || v > 255 {
+			return fmt.Errorf("%w: компонента %c должна быть в диапазоне 0–255: %d", ErrInvalidColor, "rgb"[i], v)
+		}
+	}
+	return nil
+}
+
+// priceValue переводит цену из API в значение столбца bag_price: nil и отрицательные — NULL.
+func priceValue(price *float64) interface{} {
+	if price == nil || *price < 0 {
+		return nil
+	}
+	return *price
+}
+
+// wrapConflict превращает нарушение уникальности в ErrConflict.
+func wrapConflict(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return fmt.Errorf("%w: %s", ErrConflict, pqErr.Constraint)
+	}
+	return fmt.Errorf("ошибка записи палитры: %w", err)
+}
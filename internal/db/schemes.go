@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotFound возвращается, если записи с таким идентификатором нет.
+var ErrNotFound = errors.New("запись не найдена")
+
+// SchemeSummary — краткие сведения о сохранённой схеме (для списка).
+type SchemeSummary struct {
+	ID             string
+	CreatedAt      time.Time
+	AccessedAt     time.Time
+	WidthCM        int
+	HeightCM       int
+	GridWidth      int
+	GridHeight     int
+	Colors         int    // число цветов
+	Drills         int    // число алмазов
+	SourceSHA256   string // ссылка на исходное изображение (SHA-256 загруженного файла)
+	PaletteVersion string
+}
+
+// SchemeRecord — сохранённая схема целиком.
+type SchemeRecord struct {
+	SchemeSummary
+	Params []byte // параметры генерации, JSON
+	Legend []byte // легенда: цвета, символы и количество алмазов, JSON
+	Grid   []byte // сетка подобранных цветов — файл .dmscheme
+}
+
+// SchemeRepository читает и пишет схемы в таблицу schemes (создаётся миграцией, см. Migrate).
+type SchemeRepository struct {
+	db *sql.DB
+}
+
+// NewSchemeRepository создаёт репозиторий схем поверх пула соединений.
+func NewSchemeRepository(db *sql.DB) *SchemeRepository {
+	return &SchemeRepository{db: db}
+}
+
+// Create сохраняет новую схему; время создания проставляется базой.
+func (r *SchemeRepository) Create(ctx context.Context, rec *SchemeRecord) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO schemes (id, width_cm, height_cm, grid_width, grid_height, colors, drills,
+			source_sha256, palette_version, params, legend, grid)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING created_at, accessed_at`,
+		rec.ID, rec.WidthCM, rec.HeightCM, rec.GridWidth, rec.GridHeight, rec.Colors, rec.Drills,
+		// JSON передаётся строкой: []byte драйвер отправил бы как bytea
+		rec.SourceSHA256, rec.PaletteVersion, string(rec.Params), string(rec.Legend), rec.Grid,
+	).Scan(&rec.CreatedAt, &rec.AccessedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения схемы: %w", err)
+	}
+	return nil
+}
+
+// Get возвращает схему по идентификатору и отмечает время обращения к ней.
+func (r *SchemeRepository) Get(ctx context.Context, id string) (*SchemeRecord, error) {
+	var rec SchemeRecord
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE schemes SET accessed_at = now() WHERE id = $1
+		RETURNING `+summaryColumns+`, params, legend, grid`, id,
+	).Scan(append(rec.scanTargets(), &rec.Params, &rec.Legend, &rec.Grid)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения схемы %s: %w", id, err)
+	}
+	return &rec, nil
+}
+
+// List возвращает схемы от новых к старым, не больше limit, пропустив offset первых.
+func (r *SchemeRepository) List(ctx context.Context, limit, offset int) ([]SchemeSummary, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+summaryColumns+` FROM schemes
+		ORDER BY created_at DESC, id
+		LIMIT $1 OFFSET $2`, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запроса к таблице schemes: %w", err)
+	}
+	defer rows.Close()
+
+	var list []SchemeSummary
+	for rows.Next() {
+		var rec SchemeRecord
+		if err := rows.Scan(rec.scanTargets()...); err != nil {
+			return nil, fmt.Errorf("ошибка чтения строки: %w", err)
+		}
+		list = append(list, rec.SchemeSummary)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка после чтения строк: %w", err)
+	}
+	return list, nil
+}
+
+// summaryColumns — столбцы SchemeSummary в порядке scanTargets.
+const summaryColumns = `id, created_at, accessed_at, width_cm, height_cm, grid_width, grid_height,
+	colors, drills, source_sha256, palette_version`
+
+// scanTargets возвращает указатели на поля SchemeSummary в порядке summaryColumns.
+func (rec *SchemeRecord) scanTargets() []interface{} {
+	s := &rec.SchemeSummary
+	return []interface{}{
+		&s.ID, &s.CreatedAt, &s.AccessedAt, &s.WidthCM, &s.HeightCM, &s.GridWidth, &s.GridHeight,
+		&s.Colors, &s.Drills, &s.SourceSHA256, &s.PaletteVersion,
+	}
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// standardPalette — стандартный набор ниток DMC (код, название, RGB) в формате SeedPalette.
+//
+//go:embed seed/dmc.csv
+var standardPalette []byte
+
+// StandardPalette возвращает встроенный стандартный набор DMC для SeedPalette.
+func StandardPalette() io.Reader {
+	return bytes.NewReader(standardPalette)
+}
+
+// SeedPalette загружает цвета палитры из CSV со столбцами dmc_code, name, r, g, b
+// (строка заголовка необязательна). Существующие коды обновляются, новые добавляются;
+// всё выполняется в одной транзакции. Возвращает число загруженных цветов.
+func SeedPalette(ctx context.Context, db *sql.DB, r io.Reader) (int, error) {
+	// 1. Читаем и проверяем CSV целиком, до записи в БД
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 5
+	cr.TrimLeadingSpace = true
+	var rows [][]interface{}
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("ошибка чтения CSV палитры: %w", err)
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(rec[0]), "dmc_code") {
+			continue // заголовок
+		}
+		code := strings.TrimSpace(rec[0])
+		if code == "" {
+			return 0, fmt.Errorf("строка %d: пустой код DMC", line)
+		}
+		row := []interface{}{code, strings.TrimSpace(rec[1])}
+		for i, v := range rec[2:] {
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil || n < 0 || n > 255 {
+				return 0, fmt.Errorf("строка %d: компонента %c должна быть числом 0–255: %q", line, "rgb"[i], v)
+			}
+			row = append(row, n)
+		}
+		rows = append(rows, row)
+	}
+
+	// 2. Записываем одной транзакцией
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO palette (dmc_code, name, r, g, b) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (dmc_code) DO UPDATE SET name = EXCLUDED.name, r = EXCLUDED.r, g = EXCLUDED.g, b = EXCLUDED.b`)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка подготовки запроса: %w", err)
+	}
+	defer stmt.Close()
+	for _, row := range rows {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
+			return 0, fmt.Errorf("ошибка записи цвета %v: %w", row[0], err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(rows), nil
+}
+
+// PaletteSize возвращает число цветов в таблице palette.
+func PaletteSize(ctx context.Context, db *sql.DB) (int, error) {
+	var n int
+	if err := db.QueryRowContext(ctx, `SELECT count(*) FROM palette`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("ошибка запроса к таблице palette: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ReadStock читает складские остатки из CSV со столбцами dmc_code, quantity
+// (строка заголовка необязательна). Количество — в алмазах, 0 — цвета нет на складе.
+func ReadStock(r io.Reader) (map[string]int, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 2
+	cr.TrimLeadingSpace = true
+	stock := map[string]int{}
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения CSV остатков: %w", err)
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(rec[0]), "dmc_code") {
+			continue // заголовок
+		}
+		code := strings.TrimSpace(rec[0])
+		if code == "" {
+			return nil, fmt.Errorf("строка %d: пустой код DMC", line)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(rec[1]))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("строка %d: остаток должен быть неотрицательным числом: %q", line, rec[1])
+		}
+		stock[code] = n
+	}
+	return stock, nil
+}
+
+// ApplyStock переносит остатки stock на цвета палитры: значения из stock заменяют
+// данные БД, цвета без записи в stock не меняются. Возвращает новый срез.
+func ApplyStock(palette []PaletteColor, stock map[string]int) []PaletteColor {
+	out := make([]PaletteColor, len(palette))
+	for i, pc := range palette {
+		if n, ok := stock[pc.DMCCode]; ok {
+			pc.Stock = n
+			pc.OutOfStock = n == 0
+		}
+		out[i] = pc
+	}
+	return out
+}
@@ -0,0 +1,146 @@
+// Package export сохраняет готовую схему в разных форматах:
+// PDF, PNG, SVG, CSV-сетку кодов, JSON, файл схемы .dmscheme,
+// OXS для вышивки крестом, PNG-сравнение с исходником, комплектацию набора
+// и ZIP-архив со всеми файлами сразу.
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"image/png"
+	"io"
+	"strings"
+
+	imagepkg "diamond-mosaic/internal/image"
+	"diamond-mosaic/internal/pdf"
+	"diamond-mosaic/internal/scheme"
+)
+
+// Format — формат выгрузки схемы.
+type Format string
+
+const (
+	FormatPDF  Format = "pdf"
+	FormatPNG  Format = "png"
+	FormatSVG  Format = "svg"
+	FormatCSV  Format = "csv"
+	FormatJSON Format = "json"
+	FormatZIP  Format = "zip"
+
+	FormatScheme Format = "dmscheme" // файл схемы для повторной печати, см. пакет scheme
+	FormatOXS    Format = "oxs"      // схема для вышивки крестом (Open Cross Stitch XML)
+	FormatBOM    Format = "bom"      // комплектация и стоимость набора в JSON, см. пакет bom
+
+	// FormatCompare — PNG с исходником, мозаикой и тепловой картой ΔE рядом.
+	// Доступен только для схем, только что построенных по изображению.
+	FormatCompare Format = "compare"
+)
+
+// ErrNoSource — у схемы нет цветов исходного изображения, сравнение построить нельзя.
+var ErrNoSource = errors.New("сравнение доступно только для схемы, построенной по изображению")
+
+// formatInfo — MIME-тип и расширение файла для формата.
+var formatInfo = map[Format]struct {
+	contentType string
+	ext         string
+}{
+	FormatPDF:  {"application/pdf", "pdf"},
+	FormatPNG:  {"image/png", "png"},
+	FormatSVG:  {"image/svg+xml", "svg"},
+	FormatCSV:  {"text/csv; charset=utf-8", "csv"},
+	FormatJSON: {"application/json", "json"},
+	FormatZIP:  {"application/zip", "zip"},
+
+	FormatScheme: {"application/gzip", "dmscheme"},
+	FormatOXS:    {"application/xml", "oxs"},
+	FormatBOM:    {"application/json", "bom.json"},
+
+	FormatCompare: {"image/png", "png"},
+}
+
+// bundleFormats — форматы, которые кладутся в ZIP-архив.
+var bundleFormats = []Format{FormatPDF, FormatPNG, FormatSVG, FormatCSV, FormatJSON, FormatScheme, FormatOXS, FormatBOM}
+
+// ParseFormat разбирает название формата; пустая строка означает PDF.
+func ParseFormat(s string) (Format, error) {
+	f := Format(strings.ToLower(strings.TrimSpace(s)))
+	if f == "" {
+		return FormatPDF, nil
+	}
+	if _, ok := formatInfo[f]; !ok {
+		return "", fmt.Errorf("неизвестный формат %q", s)
+	}
+	return f, nil
+}
+
+// ContentType возвращает MIME-тип формата.
+func (f Format) ContentType() string {
+	return formatInfo[f].contentType
+}
+
+// FileName возвращает имя файла для скачивания с расширением формата.
+func (f Format) FileName(base string) string {
+	return base + "." + formatInfo[f].ext
+}
+
+// Write записывает схему res в формате f.
+func Write(w io.Writer, f Format, res imagepkg.Result) error {
+	switch f {
+	case FormatPDF:
+		pdfBytes, err := pdf.GeneratePDF(res.Mosaic, res.Usages, res.Size, res.Substitutions, Kit(res))
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(pdfBytes)
+		return err
+	case FormatPNG:
+		return png.Encode(w, res.Mosaic)
+	case FormatSVG:
+		return WriteSVG(w, res)
+	case FormatCSV:
+		return WriteCSV(w, res)
+	case FormatJSON:
+		return WriteJSON(w, res)
+	case FormatZIP:
+		return WriteZIP(w, res)
+	case FormatScheme:
+		return scheme.Write(w, scheme.FromResult(res), true)
+	case FormatOXS:
+		return WriteOXS(w, res)
+	case FormatBOM:
+		return WriteBOM(w, res)
+	case FormatCompare:
+		img, _, ok := imagepkg.RenderComparison(res)
+		if !ok {
+			return ErrNoSource
+		}
+		return png.Encode(w, img)
+	}
+	return fmt.Errorf("неизвестный формат %q", f)
+}
+
+// WriteZIP записывает архив со схемой во всех форматах.
+func WriteZIP(w io.Writer, res imagepkg.Result) error {
+	zw := zip.NewWriter(w)
+	for _, f := range bundleFormats {
+		fw, err := zw.Create(f.FileName("mosaic"))
+		if err != nil {
+			return fmt.Errorf("ошибка создания файла в архиве: %w", err)
+		}
+		if err := Write(fw, f, res); err != nil {
+			return fmt.Errorf("ошибка записи %s в архив: %w", f, err)
+		}
+	}
+	return zw.Close()
+}
+
+// Bytes записывает схему в формате f в память.
+func Bytes(f Format, res imagepkg.Result) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := Write(&buf, f, res); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,176 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"diamond-mosaic/internal/bom"
+	"diamond-mosaic/internal/db"
+	imagepkg "diamond-mosaic/internal/image"
+)
+
+// blankCode — код пустых клеток вне вписанного изображения.
+const blankCode = "BLANK"
+
+// LegendEntry — строка легенды в JSON.
+type LegendEntry struct {
+	DMCCode string `json:"dmc_code"`
+	Name    string `json:"name"`
+	Symbol  string `json:"symbol"`
+	Hex     string `json:"hex"`
+	RGB     [3]int `json:"rgb"`
+	Count   int    `json:"count"`
+}
+
+// SchemeJSON — полное описание схемы для внешних программ.
+// Codes и Symbols — сетки размера Height×Width (строки сверху вниз);
+// пустые клетки вне изображения обозначены пустой строкой.
+type SchemeJSON struct {
+	Width   int                     `json:"width"`
+	Height  int                     `json:"height"`
+	Size    imagepkg.MosaicSizeInfo `json:"size"`
+	Legend  []LegendEntry           `json:"legend"`
+	Codes   [][]string              `json:"codes"`
+	Symbols [][]string              `json:"symbols"`
+
+	// Quality — ошибка цветопередачи; есть только у схем, построенных по изображению.
+	Quality *imagepkg.QualityStats `json:"quality,omitempty"`
+
+	// Substitutions — замены цветов из-за складских остатков.
+	Substitutions []SubstitutionEntry `json:"substitutions,omitempty"`
+
+	// Kit — комплектация и стоимость набора.
+	Kit *bom.BOM `json:"kit"`
+}
+
+// SubstitutionEntry — замена цвета в JSON: Count клеток цвета From выложены цветом To.
+type SubstitutionEntry struct {
+	From   SubstitutionColor `json:"from"`
+	To     SubstitutionColor `json:"to"`
+	Count  int               `json:"count"`
+	Reason string            `json:"reason"` // out_of_stock или stock_limit
+}
+
+// SubstitutionColor — цвет в описании замены.
+type SubstitutionColor struct {
+	DMCCode string `json:"dmc_code"`
+	Name    string `json:"name"`
+	Symbol  string `json:"symbol,omitempty"` // только у заменяющего цвета
+	Hex     string `json:"hex"`
+}
+
+// NewSchemeJSON собирает JSON-описание схемы из результата генерации.
+func NewSchemeJSON(res imagepkg.Result) SchemeJSON {
+	s := SchemeJSON{
+		Height: len(res.Matched),
+		Size:   res.Size,
+		Legend: Legend(res.Usages),
+	}
+	if s.Height > 0 {
+		s.Width = len(res.Matched[0])
+	}
+	if q, ok := imagepkg.Quality(res); ok {
+		s.Quality = &q
+	}
+	s.Substitutions = substitutionEntries(res)
+	s.Kit = Kit(res)
+	s.Codes = make([][]string, s.Height)
+	s.Symbols = make([][]string, s.Height)
+	for y, row := range res.Matched {
+		s.Codes[y] = make([]string, len(row))
+		s.Symbols[y] = make([]string, len(row))
+		for x, pc := range row {
+			if pc.DMCCode == blankCode {
+				continue
+			}
+			s.Codes[y][x] = pc.DMCCode
+			s.Symbols[y][x] = pc.Symbol
+		}
+	}
+	return s
+}
+
+// substitutionEntries описывает замены цветов; у заменяющего цвета — символ со схемы.
+func substitutionEntries(res imagepkg.Result) []SubstitutionEntry {
+	if len(res.Substitutions) == 0 {
+		return nil
+	}
+	symbols := make(map[string]string, len(res.Usages))
+	for _, u := range res.Usages {
+		symbols[u.PaletteColor.DMCCode] = u.PaletteColor.Symbol
+	}
+	color := func(pc db.PaletteColor, symbol string) SubstitutionColor {
+		e := newLegendEntry(pc, 0)
+		return SubstitutionColor{DMCCode: e.DMCCode, Name: e.Name, Symbol: symbol, Hex: e.Hex}
+	}
+	entries := make([]SubstitutionEntry, 0, len(res.Substitutions))
+	for _, sub := range res.Substitutions {
+		entries = append(entries, SubstitutionEntry{
+			From:   color(sub.From, ""),
+			To:     color(sub.To, symbols[sub.To.DMCCode]),
+			Count:  sub.Count,
+			Reason: string(sub.Reason),
+		})
+	}
+	return entries
+}
+
+// Legend превращает список использованных цветов в легенду (без пустых клеток).
+func Legend(usages []imagepkg.ColorUsage) []LegendEntry {
+	filtered := legendUsages(usages)
+	legend := make([]LegendEntry, 0, len(filtered))
+	for _, u := range filtered {
+		legend = append(legend, newLegendEntry(u.PaletteColor, u.Count))
+	}
+	return legend
+}
+
+// legendUsages возвращает использованные цвета без пустых клеток.
+func legendUsages(usages []imagepkg.ColorUsage) []imagepkg.ColorUsage {
+	filtered := make([]imagepkg.ColorUsage, 0, len(usages))
+	for _, u := range usages {
+		if u.PaletteColor.DMCCode != blankCode {
+			filtered = append(filtered, u)
+		}
+	}
+	return filtered
+}
+
+// newLegendEntry заполняет строку легенды по цвету палитры.
+func newLegendEntry(pc db.PaletteColor, count int) LegendEntry {
+	r, g, b := pc.Color.RGB255()
+	return LegendEntry{
+		DMCCode: pc.DMCCode,
+		Name:    pc.Name,
+		Symbol:  pc.Symbol,
+		Hex:     fmt.Sprintf("#%02x%02x%02x", r, g, b),
+		RGB:     [3]int{int(r), int(g), int(b)},
+		Count:   count,
+	}
+}
+
+// WriteJSON записывает схему в JSON.
+func WriteJSON(w io.Writer, res imagepkg.Result) error {
+	return json.NewEncoder(w).Encode(NewSchemeJSON(res))
+}
+
+// WriteCSV записывает сетку кодов DMC: одна строка CSV на ряд схемы,
+// пустые клетки — пустые ячейки.
+func WriteCSV(w io.Writer, res imagepkg.Result) error {
+	cw := csv.NewWriter(w)
+	for _, row := range res.Matched {
+		record := make([]string, len(row))
+		for x, pc := range row {
+			if pc.DMCCode != blankCode {
+				record[x] = pc.DMCCode
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,33 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+
+	"diamond-mosaic/internal/bom"
+	imagepkg "diamond-mosaic/internal/image"
+)
+
+// Kit возвращает комплектацию набора для схемы: посчитанную заранее (res.Kit)
+// или, если её нет, с параметрами по умолчанию и без цен.
+func Kit(res imagepkg.Result) *bom.BOM {
+	if res.Kit != nil {
+		return res.Kit
+	}
+	return bom.Calculate(ColorCounts(res.Usages), res.Size.BaseWidthCM, res.Size.BaseHeightCM, bom.DefaultSettings(), nil)
+}
+
+// ColorCounts переводит использованные цвета схемы в количества для расчёта комплектации.
+func ColorCounts(usages []imagepkg.ColorUsage) []bom.ColorCount {
+	filtered := legendUsages(usages)
+	counts := make([]bom.ColorCount, len(filtered))
+	for i, u := range filtered {
+		counts[i] = bom.ColorCount{Color: u.PaletteColor, Count: u.Count}
+	}
+	return counts
+}
+
+// WriteBOM записывает комплектацию и стоимость набора в JSON.
+func WriteBOM(w io.Writer, res imagepkg.Result) error {
+	return json.NewEncoder(w).Encode(Kit(res))
+}
@@ -0,0 +1,94 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+
+	imagepkg "diamond-mosaic/internal/image"
+	"diamond-mosaic/internal/oxs"
+)
+
+// mmPerInch — миллиметров в дюйме, для пересчёта размера алмаза в «крестики на дюйм».
+const mmPerInch = 25.4
+
+// WriteOXS записывает схему в формате OXS (Open Cross Stitch XML): палитру с символами,
+// полный крестик на каждый алмаз и метаданные (размер, плотность, параметры генерации).
+// Пустые клетки вне изображения остаются незашитыми.
+func WriteOXS(w io.Writer, res imagepkg.Result) error {
+	legend := legendUsages(res.Usages)
+
+	height := len(res.Matched)
+	width := 0
+	if height > 0 {
+		width = len(res.Matched[0])
+	}
+
+	chart := oxs.Chart{
+		Format: oxs.Format{Comments01: "Создано генератором схем diamond-mosaic"},
+		Properties: oxs.Properties{
+			OXSVersion:   oxs.Version,
+			Software:     "diamond-mosaic",
+			Width:        width,
+			Height:       height,
+			Title:        "Мозаика",
+			Instructions: oxsInstructions(res),
+			PaletteCount: len(legend),
+		},
+	}
+	if drill := res.Params.Options.DrillSizeMM; drill > 0 {
+		chart.Properties.StitchesPerInch = strconv.FormatFloat(mmPerInch/drill, 'f', 2, 64)
+	}
+
+	// 1. Палитра: ткань и цвета в порядке легенды
+	chart.Palette = append(chart.Palette, oxs.PaletteItem{
+		Index: oxs.ClothIndex, Number: "cloth", Name: "cloth", Color: "FFFFFF",
+	})
+	index := make(map[string]int, len(legend))
+	for i, u := range legend {
+		pc := u.PaletteColor
+		r, g, b := pc.Color.RGB255()
+		index[pc.DMCCode] = i + 1
+		chart.Palette = append(chart.Palette, oxs.PaletteItem{
+			Index:   i + 1,
+			Number:  "DMC " + pc.DMCCode,
+			Name:    pc.Name,
+			Color:   fmt.Sprintf("%02X%02X%02X", r, g, b),
+			Symbol:  pc.Symbol,
+			Strands: "2",
+		})
+	}
+
+	// 2. Крестики: по одному на каждую непустую клетку
+	for y, row := range res.Matched {
+		for x, pc := range row {
+			i, ok := index[pc.DMCCode]
+			if !ok {
+				continue // пустая клетка
+			}
+			chart.FullStitches = append(chart.FullStitches, oxs.Stitch{X: x, Y: y, PalIndex: i})
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", " ")
+	if err := enc.Encode(chart); err != nil {
+		return fmt.Errorf("ошибка формирования OXS: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// oxsInstructions формирует текст инструкции с размерами схемы.
+func oxsInstructions(res imagepkg.Result) string {
+	s := res.Size
+	return fmt.Sprintf(
+		"Основа %d x %d см (%d x %d клеток), изображение %d x %d см (%d x %d клеток)",
+		s.BaseWidthCM, s.BaseHeightCM, s.BaseWidthPX, s.BaseHeightPX,
+		s.ImgWidthCM, s.ImgHeightCM, s.ImgWidthPX, s.ImgHeightPX,
+	)
+}
@@ -0,0 +1,105 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+
+	"diamond-mosaic/fonts"
+	imagepkg "diamond-mosaic/internal/image"
+
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// Разметка SVG в пользовательских единицах (1 клетка = svgCell).
+const (
+	svgCell       = 10.0
+	svgLegendRow  = 14.0
+	svgLegendCols = 6
+	svgLegendColW = 120.0
+)
+
+// WriteSVG записывает схему в SVG: клетки с символами и легенду под схемой.
+// Символы размещаются так же, как в PNG и PDF (по измерениям встроенного шрифта).
+func WriteSVG(w io.Writer, res imagepkg.Result) error {
+	bw := bufio.NewWriter(w)
+	legend := legendUsages(res.Usages)
+
+	height := len(res.Matched)
+	width := 0
+	if height > 0 {
+		width = len(res.Matched[0])
+	}
+	gridW := float64(width) * svgCell
+	gridH := float64(height) * svgCell
+	legendRows := (len(legend) + svgLegendCols - 1) / svgLegendCols
+	totalW := gridW
+	if lw := svgLegendCols * svgLegendColW; lw > totalW {
+		totalW = lw
+	}
+	totalH := gridH + svgCell + float64(legendRows)*svgLegendRow
+
+	fmt.Fprintf(bw, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%g" height="%g" viewBox="0 0 %g %g" font-family="DejaVu Sans, sans-serif">`+"\n",
+		totalW, totalH, totalW, totalH)
+	fmt.Fprintf(bw, `<rect width="%g" height="%g" fill="#ffffff"/>`+"\n", totalW, totalH)
+
+	// 1. Клетки схемы
+	fmt.Fprintln(bw, `<g stroke="#5a5a5a" stroke-width="0.5">`)
+	for y, row := range res.Matched {
+		for x, pc := range row {
+			r, g, b := pc.Color.RGB255()
+			fmt.Fprintf(bw, `<rect x="%g" y="%g" width="%g" height="%g" fill="#%02x%02x%02x"/>`+"\n",
+				float64(x)*svgCell, float64(y)*svgCell, svgCell, svgCell, r, g, b)
+		}
+	}
+	fmt.Fprintln(bw, `</g>`)
+
+	// 2. Символы поверх клеток
+	for y, row := range res.Matched {
+		for x, pc := range row {
+			if pc.Symbol == "" || pc.DMCCode == blankCode {
+				continue
+			}
+			if err := writeSVGSymbol(bw, pc.Symbol, symbolFill(pc.Color), float64(x)*svgCell, float64(y)*svgCell, svgCell); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 3. Легенда: квадрат с символом, код и количество
+	for i, u := range legend {
+		x := float64(i%svgLegendCols) * svgLegendColW
+		y := gridH + svgCell + float64(i/svgLegendCols)*svgLegendRow
+		fmt.Fprintf(bw, `<rect x="%g" y="%g" width="%g" height="%g" fill="%s" stroke="#5a5a5a" stroke-width="0.5"/>`+"\n",
+			x, y, svgCell, svgCell, u.PaletteColor.Color.Hex())
+		if err := writeSVGSymbol(bw, u.PaletteColor.Symbol, symbolFill(u.PaletteColor.Color), x, y, svgCell); err != nil {
+			return err
+		}
+		fmt.Fprintf(bw, `<text x="%g" y="%g" font-size="8">%s (%d)</text>`+"\n",
+			x+svgCell+3, y+svgCell-2, html.EscapeString(u.PaletteColor.DMCCode), u.Count)
+	}
+
+	fmt.Fprintln(bw, `</svg>`)
+	return bw.Flush()
+}
+
+// writeSVGSymbol выводит символ, отцентрованный в квадрате (x, y, box).
+func writeSVGSymbol(w io.Writer, symbol, fill string, x, y, box float64) error {
+	size, dx, dy, err := fonts.FitSymbol(symbol, box, imagepkg.SymbolFill)
+	if err != nil {
+		return fmt.Errorf("ошибка измерения символа %q: %w", symbol, err)
+	}
+	_, err = fmt.Fprintf(w, `<text x="%.2f" y="%.2f" font-size="%.2f" fill="%s">%s</text>`+"\n",
+		x+dx, y+dy, size, fill, html.EscapeString(symbol))
+	return err
+}
+
+// symbolFill выбирает цвет символа по светлоте фона (как в PNG и PDF).
+func symbolFill(c colorful.Color) string {
+	if l, _, _ := c.Lab(); l > 0.5 {
+		return "#000000"
+	}
+	return "#ffffff"
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"diamond-mosaic/fonts"
+	"diamond-mosaic/internal/db"
+	"diamond-mosaic/internal/image"
+)
+
+// maxSymbolLen — наибольшая длина закреплённого символа (в знаках), чтобы он помещался в клетку.
+const maxSymbolLen = 3
+
+// PaletteAdmin — сервис управления палитрой для админ-API; nil — админ-API выключен.
+var PaletteAdmin *db.PaletteService
+
+// adminToken — токен, который должен прийти в заголовке Authorization: Bearer.
+var adminToken string
+
+// SetPaletteAdmin включает админ-API палитры с токеном token.
+// С пустым токеном админ-API остаётся выключенным.
+func SetPaletteAdmin(svc *db.PaletteService, token string) {
+	if token == "" {
+		svc = nil
+	}
+	PaletteAdmin = svc
+	adminToken = token
+}
+
+// adminOnly пропускает запрос дальше, только если админ-API включён и передан верный токен.
+func adminOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if PaletteAdmin == nil {
+			writeAPIError(w, http.StatusNotFound, "неизвестный метод API")
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeAPIError(w, http.StatusUnauthorized, "требуется токен администратора")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// adminPaletteHandler обрабатывает GET (список) и POST (новый цвет) /api/v1/admin/palette.
+func adminPaletteHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		entries, err := PaletteAdmin.List(r.Context())
+		if err != nil {
+			writePaletteAdminError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"colors": entries})
+
+	case http.MethodPost:
+		// Новый цвет по умолчанию включён и есть на складе
+		e := db.PaletteEntry{Enabled: true, InStock: true}
+		if !decodeAdminBody(w, r, &e) || !validSymbol(w, e.Symbol) {
+			return
+		}
+		created, err := PaletteAdmin.Create(r.Context(), e)
+		if err != nil {
+			writePaletteAdminError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, created)
+
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, "Метод не поддерживается")
+	}
+}
+
+// adminPaletteColorHandler обрабатывает PATCH /api/v1/admin/palette/{code}
+// и PUT /api/v1/admin/palette/order.
+func adminPaletteColorHandler(w http.ResponseWriter, r *http.Request) {
+	code := strings.TrimPrefix(r.URL.Path, APIPrefix+"/admin/palette/")
+	if code == "" || strings.Contains(code, "/") {
+		writeAPIError(w, http.StatusNotFound, "цвет не найден")
+		return
+	}
+
+	// 1. Порядок палитры
+	if code == "order" {
+		if r.Method != http.MethodPut {
+			writeAPIError(w, http.StatusMethodNotAllowed, "Метод не поддерживается")
+			return
+		}
+		var body struct {
+			Codes []string `json:"codes"`
+		}
+		if !decodeAdminBody(w, r, &body) {
+			return
+		}
+		if err := PaletteAdmin.Reorder(r.Context(), body.Codes); err != nil {
+			writePaletteAdminError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// 2. Изменение одного цвета
+	if r.Method != http.MethodPatch {
+		writeAPIError(w, http.StatusMethodNotAllowed, "Метод не поддерживается")
+		return
+	}
+	var u db.PaletteUpdate
+	if !decodeAdminBody(w, r, &u) {
+		return
+	}
+	if u.Symbol != nil && !validSymbol(w, *u.Symbol) {
+		return
+	}
+	updated, err := PaletteAdmin.Update(r.Context(), code, u)
+	if err != nil {
+		writePaletteAdminError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// decodeAdminBody читает JSON-тело запроса в v; при ошибке сам отвечает 400.
+func decodeAdminBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "Некорректный JSON: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// validSymbol проверяет, что закреплённый символ короткий, есть во встроенном шрифте и не путается
+// с другими (см. image.AllowedSymbol).
+func validSymbol(w http.ResponseWriter, symbol string) bool {
+	symbol = strings.TrimSpace(symbol)
+	if symbol == "" {
+		return true
+	}
+	if utf8.RuneCountInString(symbol) > maxSymbolLen || !fonts.HasGlyphs(symbol) {
+		writeAPIError(w, http.StatusBadRequest, "символ должен быть не длиннее 3 знаков и присутствовать в шрифте")
+		return false
+	}
+	if !image.AllowedSymbol(symbol) {
+		writeAPIError(w, http.StatusBadRequest, "символ легко спутать с другими: выберите символ из набора схемы или сочетание заглавных букв")
+		return false
+	}
+	return true
+}
+
+// writePaletteAdminError переводит ошибку сервиса палитры в HTTP-ответ.
+func writePaletteAdminError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, db.ErrInvalidColor):
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, db.ErrNotFound):
+		writeAPIError(w, http.StatusNotFound, "цвет не найден")
+	case errors.Is(err, db.ErrConflict):
+		writeAPIError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, context.Canceled):
+		// клиент ушёл — отвечать некому
+	default:
+		log.Printf("Ошибка админ-API палитры: %v", err)
+		writeAPIError(w, http.StatusInternalServerError, "Ошибка работы с палитрой")
+	}
+}
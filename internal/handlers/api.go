@@ -0,0 +1,428 @@
+package handlers
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"diamond-mosaic/internal/db"
+	"diamond-mosaic/internal/export"
+	"diamond-mosaic/internal/image"
+	"diamond-mosaic/internal/scheme"
+)
+
+// APIPrefix — префикс версионированного JSON API.
+const APIPrefix = "/api/v1"
+
+// maxUploadSize — ограничение на размер запроса с изображением.
+const maxUploadSize = 64 << 20
+
+// previewMaxSide — большая сторона картинки предпросмотра в пикселях.
+const previewMaxSide = 480
+
+// openAPISpec — описание API в формате OpenAPI 3.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// Schemes — хранилище сгенерированных схем, из которого API отдаёт схемы по идентификатору.
+var Schemes scheme.Store = scheme.NewMemoryStore(200)
+
+// SetSchemeStore устанавливает хранилище схем для обработчиков.
+func SetSchemeStore(s scheme.Store) {
+	Schemes = s
+}
+
+// APIHandler возвращает обработчик всех маршрутов /api/v1.
+func APIHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(APIPrefix+"/generate", apiGenerateHandler)
+	mux.HandleFunc(APIPrefix+"/preview", apiPreviewHandler)
+	mux.HandleFunc(APIPrefix+"/palettes", apiPalettesHandler)
+	mux.HandleFunc(APIPrefix+"/schemes", apiSchemesHandler)
+	mux.HandleFunc(APIPrefix+"/schemes/", apiSchemeHandler)
+	mux.HandleFunc(APIPrefix+"/openapi.json", apiOpenAPIHandler)
+	mux.HandleFunc(APIPrefix+"/admin/palette", adminOnly(adminPaletteHandler))
+	mux.HandleFunc(APIPrefix+"/admin/palette/", adminOnly(adminPaletteColorHandler))
+	mux.HandleFunc(APIPrefix+"/", func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusNotFound, "неизвестный метод API")
+	})
+	return mux
+}
+
+// apiError — тело ответа с ошибкой.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// writeJSON отправляет v в виде JSON с кодом status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Ошибка записи ответа: %v", err)
+	}
+}
+
+// writeAPIError отправляет ошибку в формате JSON.
+func writeAPIError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, apiError{Error: msg})
+}
+
+// apiGenerateRequest — параметры генерации в JSON-варианте запроса.
+// Изображение передаётся в ImageBase64 (допускается префикс data:image/png;base64,).
+// Незаданные параметры генерации (размер алмаза, порог редких цветов, учёт остатков,
+// Preprocess, Adjust и Cleanup) берутся из настроек сервера.
+type apiGenerateRequest struct {
+	ImageBase64 string              `json:"image_base64"`
+	WidthCM     int                 `json:"width_cm"`
+	HeightCM    int                 `json:"height_cm"`
+	Output      string              `json:"output"`
+	DrillSize   float64             `json:"drill_size"` // размер алмаза, мм
+	RareMin     int                 `json:"rare_min"`   // минимум алмазов цвета
+	Inventory   image.InventoryMode `json:"inventory"`  // учёт складских остатков
+	Preprocess  image.Preprocess    `json:"preprocess"`
+	Adjust      image.Adjustments   `json:"adjust"`
+	Cleanup     image.Cleanup       `json:"cleanup"`
+}
+
+// setOptions заполняет параметры генерации запроса из opts.
+func (req *apiGenerateRequest) setOptions(opts image.Options) {
+	req.DrillSize = opts.DrillSizeMM
+	req.RareMin = opts.RareColorMin
+	req.Inventory = opts.Inventory
+	req.Preprocess = opts.Preprocess
+	req.Adjust = opts.Adjust
+	req.Cleanup = opts.Cleanup
+}
+
+// options возвращает параметры генерации сервера с параметрами из запроса.
+func (req apiGenerateRequest) options() image.Options {
+	opts := ProcessOptions
+	opts.DrillSizeMM = req.DrillSize
+	opts.RareColorMin = req.RareMin
+	opts.Inventory = req.Inventory
+	opts.Preprocess = req.Preprocess
+	opts.Adjust = req.Adjust
+	opts.Cleanup = req.Cleanup
+	return opts
+}
+
+// readGenerateRequest разбирает запрос генерации: multipart-форму (поля file, width, height, output
+// и поля параметров генерации, см. parseOptions) или JSON (apiGenerateRequest).
+// Возвращает изображение и параметры.
+func readGenerateRequest(w http.ResponseWriter, r *http.Request) (io.Reader, apiGenerateRequest, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		var req apiGenerateRequest
+		req.setOptions(ProcessOptions)
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, req, fmt.Errorf("некорректный JSON: %v", err)
+		}
+		if err := validateSizeCm(req.WidthCM, req.HeightCM); err != nil {
+			return nil, req, err
+		}
+		if err := validateBaseOptions(req.options()); err != nil {
+			return nil, req, err
+		}
+		if err := req.Preprocess.Validate(); err != nil {
+			return nil, req, fmt.Errorf("некорректные параметры предобработки: %v", err)
+		}
+		if err := req.Adjust.Validate(); err != nil {
+			return nil, req, fmt.Errorf("некорректные параметры коррекции: %v", err)
+		}
+		if err := req.Cleanup.Validate(); err != nil {
+			return nil, req, fmt.Errorf("некорректные параметры очистки: %v", err)
+		}
+		data := req.ImageBase64
+		if i := strings.Index(data, ","); i >= 0 && strings.HasPrefix(data, "data:") {
+			data = data[i+1:]
+		}
+		img, err := base64.StdEncoding.DecodeString(data)
+		if err != nil || len(img) == 0 {
+			return nil, req, fmt.Errorf("некорректное изображение в image_base64")
+		}
+		return bytes.NewReader(img), req, nil
+	}
+
+	widthCm, heightCm, err := parseSizeCm(r.FormValue("width"), r.FormValue("height"))
+	if err != nil {
+		return nil, apiGenerateRequest{}, err
+	}
+	req := apiGenerateRequest{WidthCM: widthCm, HeightCM: heightCm, Output: r.FormValue("output")}
+	opts, err := parseOptions(r)
+	if err != nil {
+		return nil, req, err
+	}
+	req.setOptions(opts)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return nil, req, fmt.Errorf("Ошибка получения файла")
+	}
+	return file, req, nil
+}
+
+// apiGenerateResponse — ответ на генерацию схемы.
+type apiGenerateResponse struct {
+	ID     string            `json:"id"`
+	Links  map[string]string `json:"links"`
+	Scheme export.SchemeJSON `json:"scheme"`
+}
+
+// apiGenerateHandler обрабатывает POST /api/v1/generate: строит схему, сохраняет её
+// и возвращает JSON со схемой и ссылками на файлы. Если задан output, отличный от json,
+// сразу возвращается файл в этом формате (идентификатор — в заголовке X-Scheme-ID).
+func apiGenerateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, "Метод не поддерживается")
+		return
+	}
+
+	// 1. Разбираем запрос
+	src, req, err := readGenerateRequest(w, r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if c, ok := src.(io.Closer); ok {
+		defer c.Close()
+	}
+	format := export.FormatJSON
+	if req.Output != "" {
+		if format, err = export.ParseFormat(req.Output); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "Некорректный формат результата")
+			return
+		}
+	}
+
+	// 2. Генерируем и сохраняем схему (или берём сетку из кеша)
+	gen, err := newGeneration(src, req.WidthCM, req.HeightCM, req.options())
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "Ошибка получения файла")
+		return
+	}
+	res, id, err := gen.process()
+	if err != nil {
+		log.Printf("Ошибка обработки изображения: %v", err)
+		writeAPIError(w, http.StatusUnprocessableEntity, fmt.Sprintf("Ошибка обработки изображения: %v", err))
+		return
+	}
+	if id == "" {
+		writeAPIError(w, http.StatusInternalServerError, "Ошибка сохранения схемы")
+		return
+	}
+
+	// 3. Отвечаем файлом или JSON; комплектация считается по снимку палитры запроса
+	w.Header().Set("X-Scheme-ID", id)
+	if format != export.FormatJSON {
+		writeScheme(w, format, res, gen.palette)
+		return
+	}
+	writeJSON(w, http.StatusCreated, apiGenerateResponse{
+		ID:     id,
+		Links:  schemeLinks(id),
+		Scheme: export.NewSchemeJSON(withKit(res, gen.palette)),
+	})
+}
+
+// schemeLinks возвращает ссылки на схему во всех форматах.
+func schemeLinks(id string) map[string]string {
+	self := APIPrefix + "/schemes/" + id
+	links := map[string]string{"self": self}
+	for _, f := range []export.Format{export.FormatPDF, export.FormatPNG, export.FormatSVG, export.FormatCSV, export.FormatScheme, export.FormatBOM} {
+		links[string(f)] = self + "?output=" + string(f)
+	}
+	return links
+}
+
+// apiPreviewResponse — быстрый предпросмотр мозаики.
+type apiPreviewResponse struct {
+	ImagePNGBase64 string               `json:"image_png_base64"`
+	Colors         int                  `json:"colors"`
+	Drills         int                  `json:"drills"`
+	Size           image.MosaicSizeInfo `json:"size"`
+	Legend         []export.LegendEntry `json:"legend"`
+	Quality        image.QualityStats   `json:"quality"`
+}
+
+// apiPreviewHandler обрабатывает POST /api/v1/preview: подбирает цвета так же, как генерация,
+// но возвращает только уменьшенную картинку без символов и статистику по цветам.
+func apiPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeAPIError(w, http.StatusMethodNotAllowed, "Метод не поддерживается")
+		return
+	}
+	src, req, err := readGenerateRequest(w, r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if c, ok := src.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	gen, err := newGeneration(src, req.WidthCM, req.HeightCM, req.options())
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "Ошибка получения файла")
+		return
+	}
+	p, err := buildPreview(gen)
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, fmt.Sprintf("Ошибка обработки изображения: %v", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, apiPreviewResponse{
+		ImagePNGBase64: base64.StdEncoding.EncodeToString(p.PNG),
+		Colors:         p.Colors,
+		Drills:         p.Drills,
+		Size:           p.Size,
+		Legend:         p.Legend,
+		Quality:        p.Quality,
+	})
+}
+
+// apiPalette — палитра в ответе API.
+type apiPalette struct {
+	ID      string               `json:"id"`
+	Version string               `json:"version"`
+	Count   int                  `json:"count"`
+	Colors  []export.LegendEntry `json:"colors"`
+}
+
+// apiPalettesHandler обрабатывает GET /api/v1/palettes: список доступных палитр с цветами.
+func apiPalettesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "Метод не поддерживается")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]apiPalette{
+		"palettes": {newAPIPalette("dmc", Palettes.Load())},
+	})
+}
+
+// newAPIPalette описывает палитру для ответа API (количество в цветах не заполняется).
+func newAPIPalette(id string, palette *db.PaletteSnapshot) apiPalette {
+	usages := make([]image.ColorUsage, len(palette.Colors))
+	for i, pc := range palette.Colors {
+		usages[i] = image.ColorUsage{PaletteColor: pc}
+	}
+	return apiPalette{
+		ID:      id,
+		Version: palette.Version,
+		Count:   palette.Len(),
+		Colors:  export.Legend(usages),
+	}
+}
+
+// apiSchemeListLimit — размер страницы списка схем по умолчанию и максимальный.
+const (
+	apiSchemeListLimit    = 50
+	apiSchemeListMaxLimit = 500
+)
+
+// apiSchemeSummary — схема в списке вместе со ссылками на файлы.
+type apiSchemeSummary struct {
+	scheme.Summary
+	Links map[string]string `json:"links"`
+}
+
+// apiSchemesHandler обрабатывает GET /api/v1/schemes?limit=&offset=: список сохранённых схем
+// от новых к старым.
+func apiSchemesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "Метод не поддерживается")
+		return
+	}
+	limit, offset := apiSchemeListLimit, 0
+	q := r.URL.Query()
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > apiSchemeListMaxLimit {
+			writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("limit должен быть от 1 до %d", apiSchemeListMaxLimit))
+			return
+		}
+		limit = n
+	}
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeAPIError(w, http.StatusBadRequest, "offset должен быть неотрицательным")
+			return
+		}
+		offset = n
+	}
+
+	list, err := Schemes.List(limit, offset)
+	if err != nil {
+		log.Printf("Ошибка чтения списка схем: %v", err)
+		writeAPIError(w, http.StatusInternalServerError, "Ошибка чтения списка схем")
+		return
+	}
+	items := make([]apiSchemeSummary, 0, len(list))
+	for _, s := range list {
+		items = append(items, apiSchemeSummary{Summary: s, Links: schemeLinks(s.ID)})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"schemes": items,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// apiSchemeHandler обрабатывает GET /api/v1/schemes/{id}: возвращает сохранённую схему
+// в формате output (по умолчанию JSON).
+func apiSchemeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAPIError(w, http.StatusMethodNotAllowed, "Метод не поддерживается")
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, APIPrefix+"/schemes/")
+	if id == "" || strings.Contains(id, "/") {
+		writeAPIError(w, http.StatusNotFound, "схема не найдена")
+		return
+	}
+
+	s, err := Schemes.Get(id)
+	if errors.Is(err, scheme.ErrNotFound) {
+		writeAPIError(w, http.StatusNotFound, "схема не найдена")
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка чтения схемы %s: %v", id, err)
+		writeAPIError(w, http.StatusInternalServerError, "Ошибка чтения схемы")
+		return
+	}
+
+	format := export.FormatJSON
+	if output := r.URL.Query().Get("output"); output != "" {
+		if format, err = export.ParseFormat(output); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "Некорректный формат результата")
+			return
+		}
+	}
+	palette := Palettes.Load()
+	if format == export.FormatJSON {
+		res := withKit(s.Result(), palette)
+		writeJSON(w, http.StatusOK, apiGenerateResponse{ID: id, Links: schemeLinks(id), Scheme: export.NewSchemeJSON(res)})
+		return
+	}
+	writeScheme(w, format, s.Result(), palette)
+}
+
+// apiOpenAPIHandler отдаёт описание API в формате OpenAPI.
+func apiOpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(openAPISpec)))
+	w.Write(openAPISpec)
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"diamond-mosaic/internal/cache"
+	"diamond-mosaic/internal/db"
+	"diamond-mosaic/internal/export"
+	"diamond-mosaic/internal/image"
+	"diamond-mosaic/internal/scheme"
+
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// Results — кеш результатов генерации: готовых файлов и промежуточных сеток цветов.
+var Results cache.Cache = cache.NewLRU(64 << 20)
+
+// SetResultCache устанавливает кеш результатов для обработчиков.
+func SetResultCache(c cache.Cache) {
+	Results = c
+}
+
+// Виды значений в кеше результатов.
+const (
+	cacheKindGrid    = "grid"    // сетка цветов с символами и цвета исходника (gridEntry)
+	cacheKindOutput  = "output:" // готовый файл, к виду добавляется формат (schemeOutput)
+	cacheKindPreview = "preview" // предпросмотр без символов (preview)
+)
+
+// generation — загруженное изображение вместе со всеми параметрами генерации
+// и снимком палитры на момент запроса. По ним вычисляются ключи кеша: одинаковый файл
+// с одинаковыми параметрами на той же палитре всегда даёт одинаковую схему.
+type generation struct {
+	data     []byte
+	sha256   string
+	widthCm  int
+	heightCm int
+	opts     image.Options
+	palette  *db.PaletteSnapshot
+}
+
+// newGeneration читает загруженный файл целиком, считает его хеш и берёт текущий снимок палитры.
+func newGeneration(r io.Reader, widthCm, heightCm int, opts image.Options) (generation, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return generation{}, err
+	}
+	sum := sha256.Sum256(data)
+	return generation{
+		data:     data,
+		sha256:   hex.EncodeToString(sum[:]),
+		widthCm:  widthCm,
+		heightCm: heightCm,
+		opts:     opts,
+		palette:  Palettes.Load(),
+	}, nil
+}
+
+// key вычисляет ключ кеша для значения вида kind. Параметры генерации входят в ключ
+// целиком (в виде JSON), поэтому новые параметры не требуют правок здесь. Параметры
+// комплектации тоже входят в ключ: от них зависит страница комплектации в готовых файлах.
+func (g generation) key(kind string) string {
+	opts, _ := json.Marshal(g.opts)
+	kit, _ := json.Marshal(KitSettings)
+	return cache.Key(kind, g.sha256, g.palette.Version,
+		strconv.Itoa(g.widthCm), strconv.Itoa(g.heightCm), string(opts), string(kit))
+}
+
+// gridEntry — сетка цветов в кеше. Цвета исходника хранятся по 3 байта на клетку.
+type gridEntry struct {
+	Scheme   *scheme.Scheme
+	Source   []byte
+	SchemeID string // идентификатор сохранённой схемы (см. Schemes)
+}
+
+// process возвращает результат генерации и идентификатор сохранённой схемы: из кеша сеток
+// или, при промахе, через image.Process с сохранением схемы в Schemes. Сетка кешируется
+// и тогда, когда схему сохранить не удалось: генерация не прерывается, идентификатор пустой,
+// а сохранить схему пробуем снова при следующем попадании в кеш.
+func (g generation) process() (image.Result, string, error) {
+	key := g.key(cacheKindGrid)
+	if entry, ok := g.cachedGrid(key); ok {
+		if entry.SchemeID == "" {
+			if entry.SchemeID = saveScheme(entry.Scheme); entry.SchemeID != "" {
+				g.storeGrid(key, entry)
+			}
+		}
+		res := entry.Scheme.Result()
+		res.Source = unpackColors(entry.Source, entry.Scheme.Width, entry.Scheme.Height)
+		return res, entry.SchemeID, nil
+	}
+
+	res, err := image.Process(bytes.NewReader(g.data), g.palette.Colors, g.widthCm, g.heightCm, g.opts)
+	if err != nil {
+		return image.Result{}, "", err
+	}
+	s := scheme.FromResult(res)
+	id := saveScheme(s)
+	g.storeGrid(key, gridEntry{Scheme: s, Source: packColors(res.Source), SchemeID: id})
+	return res, id, nil
+}
+
+// saveScheme сохраняет схему в Schemes и возвращает её идентификатор; при ошибке
+// пишет её в журнал и возвращает пустую строку.
+func saveScheme(s *scheme.Scheme) string {
+	id, err := Schemes.Save(s)
+	if err != nil {
+		log.Printf("Ошибка сохранения схемы: %v", err)
+		return ""
+	}
+	return id
+}
+
+// cachedGrid достаёт сетку из кеша.
+func (g generation) cachedGrid(key string) (gridEntry, bool) {
+	data, ok := Results.Get(key)
+	if !ok {
+		return gridEntry{}, false
+	}
+	var entry gridEntry
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entry); err != nil || entry.Scheme.Validate() != nil {
+		log.Printf("Повреждённая сетка в кеше %s", key)
+		return gridEntry{}, false
+	}
+	return entry, true
+}
+
+// storeGrid кладёт сетку в кеш.
+func (g generation) storeGrid(key string, entry gridEntry) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(entry); err != nil {
+		log.Printf("Ошибка записи сетки в кеш: %v", err)
+		return
+	}
+	Results.Put(key, buf.Bytes())
+}
+
+// cachedOutput достаёт из кеша готовый файл в формате format.
+func (g generation) cachedOutput(format export.Format) (schemeOutput, bool) {
+	data, ok := Results.Get(g.key(cacheKindOutput + string(format)))
+	if !ok {
+		return schemeOutput{}, false
+	}
+	var out schemeOutput
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&out); err != nil {
+		return schemeOutput{}, false
+	}
+	return out, true
+}
+
+// storeOutput кладёт готовый файл в кеш.
+func (g generation) storeOutput(format export.Format, out schemeOutput) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(out); err != nil {
+		log.Printf("Ошибка записи файла в кеш: %v", err)
+		return
+	}
+	Results.Put(g.key(cacheKindOutput+string(format)), buf.Bytes())
+}
+
+// setCacheHeader сообщает клиенту, взят ли результат из кеша.
+func setCacheHeader(w http.ResponseWriter, hit bool) {
+	if hit {
+		w.Header().Set("X-Cache", "HIT")
+	} else {
+		w.Header().Set("X-Cache", "MISS")
+	}
+}
+
+// packColors упаковывает сетку цветов в байты RGB (по 3 байта на клетку).
+func packColors(colors [][]colorful.Color) []byte {
+	var packed []byte
+	for _, row := range colors {
+		for _, c := range row {
+			r, g, b := c.Clamped().RGB255()
+			packed = append(packed, r, g, b)
+		}
+	}
+	return packed
+}
+
+// unpackColors восстанавливает сетку цветов w×h из байтов RGB; nil, если размер не совпадает.
+func unpackColors(packed []byte, w, h int) [][]colorful.Color {
+	if len(packed) != w*h*3 {
+		return nil
+	}
+	colors := make([][]colorful.Color, h)
+	for y := range colors {
+		colors[y] = make([]colorful.Color, w)
+		for x := range colors[y] {
+			i := (y*w + x) * 3
+			colors[y][x] = colorful.Color{
+				R: float64(packed[i]) / 255.0,
+				G: float64(packed[i+1]) / 255.0,
+				B: float64(packed[i+2]) / 255.0,
+			}
+		}
+	}
+	return colors
+}
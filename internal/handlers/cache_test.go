@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	stdimage "image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"diamond-mosaic/internal/cache"
+	"diamond-mosaic/internal/db"
+	"diamond-mosaic/internal/image"
+	"diamond-mosaic/internal/scheme"
+
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// flakyStore — хранилище схем, сохранение в которое можно сломать.
+type flakyStore struct {
+	scheme.Store
+	fail  bool
+	saves int
+}
+
+func (s *flakyStore) Save(sch *scheme.Scheme) (string, error) {
+	if s.fail {
+		return "", errors.New("хранилище недоступно")
+	}
+	s.saves++
+	return s.Store.Save(sch)
+}
+
+// testPNG кодирует небольшое двухцветное изображение в PNG.
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+	img := stdimage.NewNRGBA(stdimage.Rect(0, 0, 20, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			c := color.NRGBA{R: 200, G: 30, B: 40, A: 255}
+			if x >= 10 {
+				c = color.NRGBA{R: 20, G: 50, B: 160, A: 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestProcessCachesWhenSaveFails проверяет, что сетка кешируется и при ошибке сохранения
+// схемы, а схема сохраняется при следующем попадании в кеш, когда хранилище снова доступно.
+func TestProcessCachesWhenSaveFails(t *testing.T) {
+	oldResults, oldSchemes, oldPalettes := Results, Schemes, Palettes
+	defer func() { Results, Schemes, Palettes = oldResults, oldSchemes, oldPalettes }()
+
+	lru := cache.NewLRU(64 << 20)
+	store := &flakyStore{Store: scheme.NewMemoryStore(10), fail: true}
+	Results, Schemes = lru, store
+	Palettes = db.NewPaletteHolder([]db.PaletteColor{
+		{DMCCode: "321", Name: "Red", Color: colorful.Color{R: 0.78, G: 0.12, B: 0.16}},
+		{DMCCode: "820", Name: "Royal Blue", Color: colorful.Color{R: 0.08, G: 0.2, B: 0.63}},
+	}, 0)
+
+	data := testPNG(t)
+	process := func() string {
+		t.Helper()
+		gen, err := newGeneration(bytes.NewReader(data), 2, 2, image.DefaultOptions())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, id, err := gen.process()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	// 1. Хранилище недоступно: схема не сохранена, но сетка в кеше
+	if id := process(); id != "" {
+		t.Errorf("идентификатор %q при недоступном хранилище", id)
+	}
+	if s := lru.Stats(); s.Puts != 1 {
+		t.Fatalf("сетка не закеширована: %+v", s)
+	}
+	if id := process(); id != "" || lru.Stats().Hits != 1 {
+		t.Errorf("повторный запрос: идентификатор %q, счётчики %+v", id, lru.Stats())
+	}
+
+	// 2. Хранилище снова доступно: схема сохраняется при попадании в кеш, и только один раз
+	store.fail = false
+	id := process()
+	if id == "" || store.saves != 1 {
+		t.Fatalf("схема не сохранена: идентификатор %q, сохранений %d", id, store.saves)
+	}
+	if again := process(); again != id || store.saves != 1 {
+		t.Errorf("идентификатор %q (ожидался %q), сохранений %d", again, id, store.saves)
+	}
+}
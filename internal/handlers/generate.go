@@ -1,74 +1,354 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
 
 	"diamond-mosaic/internal/db"
+	"diamond-mosaic/internal/export"
 	"diamond-mosaic/internal/image"
-	"diamond-mosaic/internal/pdf"
 )
 
-// Palette — глобальная палитра из базы данных DMC, используется всеми обработчиками для подбора цветов.
-var Palette []db.PaletteColor
+// Palettes — палитра DMC из базы данных, общая для всех обработчиков. Обработчик берёт
+// снимок (Palettes.Load()) один раз на запрос, поэтому перезагрузка палитры
+// не затрагивает уже начатые генерации.
+var Palettes = db.NewPaletteHolder(nil, 0)
 
-// SetPaletteFromDB устанавливает глобальную палитру для использования в обработчиках.
+// SetPaletteHolder устанавливает хранилище палитры, которое перезагружает сервер.
+func SetPaletteHolder(h *db.PaletteHolder) {
+	Palettes = h
+}
+
+// SetPaletteFromDB заменяет палитру, используемую обработчиками.
 func SetPaletteFromDB(p []db.PaletteColor) {
-	Palette = p
+	Palettes.Store(p)
+}
+
+// ProcessOptions — общие параметры генерации схемы из конфигурации сервера.
+var ProcessOptions = image.DefaultOptions()
+
+// SetProcessOptions устанавливает параметры генерации для обработчиков.
+func SetProcessOptions(opts image.Options) {
+	ProcessOptions = opts
+}
+
+// maxSizeCm — максимальная сторона основы в см.
+const maxSizeCm = 2000
+
+// parseSizeCm разбирает размеры основы из строковых параметров запроса.
+func parseSizeCm(widthStr, heightStr string) (int, int, error) {
+	if widthStr == "" || heightStr == "" {
+		return 0, 0, fmt.Errorf("Не указаны размеры")
+	}
+	widthCm, errW := strconv.Atoi(widthStr)
+	heightCm, errH := strconv.Atoi(heightStr)
+	if errW != nil || errH != nil {
+		return 0, 0, fmt.Errorf("Некорректные размеры")
+	}
+	return widthCm, heightCm, validateSizeCm(widthCm, heightCm)
+}
+
+// validateSizeCm проверяет, что размеры основы в допустимых пределах.
+func validateSizeCm(widthCm, heightCm int) error {
+	if widthCm <= 0 || heightCm <= 0 || widthCm > maxSizeCm || heightCm > maxSizeCm {
+		return fmt.Errorf("Некорректные размеры")
+	}
+	return nil
+}
+
+// parseOptions берёт параметры генерации сервера и переопределяет их необязательными
+// полями формы: drill_size (размер алмаза в мм), rare_min (минимум алмазов цвета)
+// и inventory (учёт складских остатков: ignore, avoid или cap), а также полями предобработки,
+// коррекции и очистки (см. parsePreprocess, parseAdjust и parseCleanup).
+func parseOptions(r *http.Request) (image.Options, error) {
+	opts := ProcessOptions
+	if v := r.FormValue("drill_size"); v != "" {
+		size, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return opts, fmt.Errorf("Некорректный размер алмаза")
+		}
+		opts.DrillSizeMM = size
+	}
+	if v := r.FormValue("rare_min"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, fmt.Errorf("Некорректный порог редких цветов")
+		}
+		opts.RareColorMin = n
+	}
+	if v := r.FormValue("inventory"); v != "" {
+		opts.Inventory = image.InventoryMode(v)
+	}
+	if err := validateBaseOptions(opts); err != nil {
+		return opts, err
+	}
+	if err := parsePreprocess(r, &opts.Preprocess); err != nil {
+		return opts, err
+	}
+	if err := parseAdjust(r, &opts.Adjust); err != nil {
+		return opts, err
+	}
+	if err := parseCleanup(r, &opts.Cleanup); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// validateBaseOptions проверяет размер алмаза (от 1 до 10 мм), порог редких цветов
+// и режим учёта остатков — параметры, общие для формы и JSON API.
+func validateBaseOptions(opts image.Options) error {
+	if !(opts.DrillSizeMM >= 1 && opts.DrillSizeMM <= 10) {
+		return fmt.Errorf("Некорректный размер алмаза")
+	}
+	if opts.RareColorMin < 0 {
+		return fmt.Errorf("Некорректный порог редких цветов")
+	}
+	if _, err := image.ParseInventoryMode(string(opts.Inventory)); err != nil {
+		return fmt.Errorf("Некорректный режим учёта остатков")
+	}
+	return nil
+}
+
+// parseCleanup переопределяет очистку от островков полями формы cleanup (способ)
+// и min_island (порог); незаданные поля остаются как в c.
+func parseCleanup(r *http.Request, c *image.Cleanup) error {
+	if v := r.FormValue("cleanup"); v != "" {
+		c.Method = image.CleanupMethod(v)
+	}
+	if v := r.FormValue("min_island"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("Некорректный размер островка")
+		}
+		c.MinIsland = n
+	}
+	if err := c.Validate(); err != nil {
+		return fmt.Errorf("Некорректные параметры очистки: %v", err)
+	}
+	return nil
+}
+
+// parseAdjust переопределяет коррекцию изображения полями формы brightness, contrast,
+// saturation, gamma, auto_levels и white_balance; незаданные поля остаются как в a.
+func parseAdjust(r *http.Request, a *image.Adjustments) error {
+	for _, f := range []struct {
+		name string
+		dst  *float64
+	}{{"brightness", &a.Brightness}, {"contrast", &a.Contrast}, {"saturation", &a.Saturation}, {"gamma", &a.Gamma}} {
+		if v := r.FormValue(f.name); v != "" {
+			value, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fmt.Errorf("Некорректное значение %s", f.name)
+			}
+			*f.dst = value
+		}
+	}
+	for _, f := range []struct {
+		name string
+		dst  *bool
+	}{{"auto_levels", &a.AutoLevels}, {"white_balance", &a.WhiteBalance}} {
+		if v := r.FormValue(f.name); v != "" {
+			on, err := parseCheckbox(v)
+			if err != nil {
+				return fmt.Errorf("Некорректное значение %s", f.name)
+			}
+			*f.dst = on
+		}
+	}
+	if err := a.Validate(); err != nil {
+		return fmt.Errorf("Некорректные параметры коррекции: %v", err)
+	}
+	return nil
+}
+
+// parseCheckbox разбирает значение флажка формы: "on" (так браузер отправляет отмеченный
+// флажок без value) или логическое значение.
+func parseCheckbox(v string) (bool, error) {
+	if v == "on" {
+		return true, nil
+	}
+	return strconv.ParseBool(v)
+}
+
+// parsePreprocess переопределяет этапы предобработки полями формы denoise, resample,
+// cell_stat, sharpen и median; незаданные поля остаются как в p.
+func parsePreprocess(r *http.Request, p *image.Preprocess) error {
+	if v := r.FormValue("denoise"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("Некорректный размер шумоподавления")
+		}
+		p.Denoise = n
+	}
+	if v := r.FormValue("resample"); v != "" {
+		p.Resample = image.ResampleFilter(v)
+	}
+	if v := r.FormValue("cell_stat"); v != "" {
+		p.CellStat = image.CellStat(v)
+	}
+	if v := r.FormValue("sharpen"); v != "" {
+		sigma, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("Некорректная резкость")
+		}
+		p.Sharpen = sigma
+	}
+	if v := r.FormValue("median"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("Некорректный размер медианного фильтра")
+		}
+		p.Median = n
+	}
+	if err := p.Validate(); err != nil {
+		return fmt.Errorf("Некорректные параметры предобработки: %v", err)
+	}
+	return nil
 }
 
-// GenerateHandler обрабатывает POST-запрос /generate и возвращает PDF-файл с мозаикой и легендой.
+// GenerateHandler обрабатывает POST-запрос /generate и возвращает схему в формате,
+// заданном параметром output (см. export.Format; по умолчанию — PDF с легендой).
 func GenerateHandler(w http.ResponseWriter, r *http.Request) {
 	// 1. Разрешён только POST-запрос
 	if r.Method != http.MethodPost {
 		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
 		return
 	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
 
-	// 2. Получаем размеры основы из формы
-	widthStr := r.FormValue("width")
-	heightStr := r.FormValue("height")
-	if widthStr == "" || heightStr == "" {
-		http.Error(w, "Не указаны размеры", http.StatusBadRequest)
+	// 2. Получаем размеры основы и параметры генерации из формы
+	widthCm, heightCm, err := parseSizeCm(r.FormValue("width"), r.FormValue("height"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts, err := parseOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	widthCm, err := strconv.Atoi(widthStr)
-	heightCm, err := strconv.Atoi(heightStr)
-	if err != nil || widthCm <= 0 || heightCm <= 0 || widthCm > 2000 || heightCm > 2000 {
-		http.Error(w, "Некорректные размеры", http.StatusBadRequest)
+
+	// 3. Определяем формат результата
+	format, err := export.ParseFormat(r.FormValue("output"))
+	if err != nil {
+		http.Error(w, "Некорректный формат результата", http.StatusBadRequest)
 		return
 	}
 
-	// 3. Получаем загруженный PNG-файл
+	// 4. Получаем загруженный PNG-файл
 	file, _, err := r.FormFile("file")
 	if err != nil {
 		http.Error(w, "Ошибка получения файла", http.StatusBadRequest)
 		return
 	}
 	defer file.Close()
+	gen, err := newGeneration(file, widthCm, heightCm, opts)
+	if err != nil {
+		http.Error(w, "Ошибка получения файла", http.StatusBadRequest)
+		return
+	}
 
-	// 4. Обрабатываем изображение: ресайз, подбор цветов, статистика
-	mosaicImg, usages, sizeInfo, err := image.Process(file, Palette, widthCm, heightCm)
+	// 5. Тот же файл с теми же параметрами уже генерировался — отдаём готовый результат
+	if out, ok := gen.cachedOutput(format); ok {
+		setCacheHeader(w, true)
+		writeOutput(w, format, out)
+		return
+	}
+	setCacheHeader(w, false)
+
+	// 6. Обрабатываем изображение: ресайз, подбор цветов, статистика (сетка тоже может быть в кеше)
+	res, id, err := gen.process()
 	if err != nil {
 		log.Printf("Ошибка обработки изображения: %v", err)
 		http.Error(w, fmt.Sprintf("Ошибка обработки изображения: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	// 5. Генерируем PDF-файл по результатам обработки
-	pdfBytes, err := pdf.GeneratePDF(mosaicImg, usages, sizeInfo)
+	// 7. Формируем файл в нужном формате, сохраняем в кеш и отправляем на скачивание
+	out, err := newSchemeOutput(format, res, gen.palette)
 	if err != nil {
-		log.Printf("Ошибка формирования PDF: %v", err)
-		http.Error(w, "Ошибка формирования PDF", http.StatusInternalServerError)
+		writeOutputError(w, format, err)
 		return
 	}
+	out.SchemeID = id
+	if id != "" {
+		gen.storeOutput(format, out)
+	}
+	writeOutput(w, format, out)
+}
 
-	// 6. Отправляем PDF-файл на скачивание
-	w.Header().Set("Content-Type", "application/pdf")
-	w.Header().Set("Content-Disposition", `attachment; filename="mosaic_with_legend.pdf"`)
-	if _, err := w.Write(pdfBytes); err != nil {
+// schemeOutput — готовый файл схемы и ошибка цветопередачи (если схема построена по изображению).
+type schemeOutput struct {
+	Data     []byte
+	Quality  *image.QualityStats
+	SchemeID string // идентификатор сохранённой схемы, если есть
+}
+
+// newSchemeOutput формирует файл схемы в формате format; комплектация набора
+// считается по ценам палитры palette.
+func newSchemeOutput(format export.Format, res image.Result, palette *db.PaletteSnapshot) (schemeOutput, error) {
+	data, err := export.Bytes(format, withKit(res, palette))
+	if err != nil {
+		return schemeOutput{}, err
+	}
+	out := schemeOutput{Data: data}
+	if q, ok := image.Quality(res); ok {
+		out.Quality = &q
+	}
+	return out, nil
+}
+
+// writeScheme формирует файл схемы в формате format и отправляет его на скачивание;
+// комплектация набора считается по ценам палитры palette — того же снимка, что и у запроса.
+func writeScheme(w http.ResponseWriter, format export.Format, res image.Result, palette *db.PaletteSnapshot) {
+	out, err := newSchemeOutput(format, res, palette)
+	if err != nil {
+		writeOutputError(w, format, err)
+		return
+	}
+	writeOutput(w, format, out)
+}
+
+// writeOutputError отвечает ошибкой формирования файла.
+func writeOutputError(w http.ResponseWriter, format export.Format, err error) {
+	if errors.Is(err, export.ErrNoSource) {
+		http.Error(w, "Сравнение доступно только при генерации схемы по изображению", http.StatusUnprocessableEntity)
+		return
+	}
+	log.Printf("Ошибка формирования %s: %v", format, err)
+	http.Error(w, fmt.Sprintf("Ошибка формирования %s", format), http.StatusInternalServerError)
+}
+
+// writeOutput отправляет готовый файл на скачивание. Среднее и максимальное ΔE
+// передаются в заголовках X-Quality-Mean-DeltaE и X-Quality-Max-DeltaE,
+// идентификатор сохранённой схемы — в X-Scheme-ID.
+func writeOutput(w http.ResponseWriter, format export.Format, out schemeOutput) {
+	fileName := format.FileName("mosaic")
+	switch format {
+	case export.FormatPDF:
+		fileName = "mosaic_with_legend.pdf"
+	case export.FormatCompare:
+		fileName = "mosaic_compare.png"
+	}
+	if out.Quality != nil {
+		setQualityHeaders(w, *out.Quality)
+	}
+	if out.SchemeID != "" {
+		w.Header().Set("X-Scheme-ID", out.SchemeID)
+	}
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+	if _, err := w.Write(out.Data); err != nil {
 		log.Printf("Ошибка записи ответа: %v", err)
 	}
 }
+
+// setQualityHeaders передаёт среднее и максимальное ΔE схемы в заголовках
+// X-Quality-Mean-DeltaE и X-Quality-Max-DeltaE.
+func setQualityHeaders(w http.ResponseWriter, q image.QualityStats) {
+	w.Header().Set("X-Quality-Mean-DeltaE", strconv.FormatFloat(q.MeanDeltaE, 'f', 2, 64))
+	w.Header().Set("X-Quality-Max-DeltaE", strconv.FormatFloat(q.MaxDeltaE, 'f', 2, 64))
+}
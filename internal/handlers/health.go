@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// draining выставляется при остановке сервера, чтобы балансировщик перестал слать запросы.
+var draining int32
+
+// SetDraining помечает сервер как останавливающийся: /readyz начинает отвечать 503.
+func SetDraining() {
+	atomic.StoreInt32(&draining, 1)
+}
+
+// HealthzHandler отвечает 200, пока процесс жив (liveness-проба).
+func HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// ReadyzHandler отвечает 200, если палитра загружена и сервер не останавливается (readiness-проба).
+func ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	switch {
+	case atomic.LoadInt32(&draining) == 1:
+		http.Error(w, "сервер останавливается", http.StatusServiceUnavailable)
+	case Palettes.Load().Len() == 0:
+		http.Error(w, "палитра не загружена", http.StatusServiceUnavailable)
+	default:
+		w.Write([]byte("ready\n"))
+	}
+}
+
+// VarsHandler отдаёт в формате expvar только переменные names. Стандартный expvar.Handler
+// публикует ещё и cmdline — аргументы запуска вместе с паролем БД и токеном администратора.
+func VarsHandler(names ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		fmt.Fprint(w, "{")
+		sep := "\n"
+		for _, name := range names {
+			v := expvar.Get(name)
+			if v == nil {
+				continue
+			}
+			fmt.Fprintf(w, "%s%q: %s", sep, name, v.String())
+			sep = ",\n"
+		}
+		fmt.Fprint(w, "\n}\n")
+	})
+}
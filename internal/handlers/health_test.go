@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVarsHandler(t *testing.T) {
+	expvar.Publish("test_palette", expvar.Func(func() interface{} { return map[string]int{"colors": 3} }))
+
+	rec := httptest.NewRecorder()
+	VarsHandler("test_palette", "missing").ServeHTTP(rec, httptest.NewRequest("GET", "/debug/vars", nil))
+
+	var vars map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &vars); err != nil {
+		t.Fatalf("ответ не JSON: %v\n%s", err, rec.Body)
+	}
+	if string(vars["test_palette"]) != `{"colors":3}` {
+		t.Errorf("test_palette = %s", vars["test_palette"])
+	}
+	// cmdline содержит аргументы запуска с секретами и не должен публиковаться
+	for _, name := range []string{"cmdline", "memstats", "missing"} {
+		if _, ok := vars[name]; ok {
+			t.Errorf("опубликована переменная %s", name)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"diamond-mosaic/internal/export"
+	"diamond-mosaic/internal/image"
+	"diamond-mosaic/internal/importer"
+)
+
+// maxPatternSize — ограничение на размер импортируемого файла схемы.
+const maxPatternSize = 32 << 20
+
+// ImportHandler обрабатывает POST-запрос /import: принимает схему из другой программы
+// (поле pattern: OXS или CSV-сетка кодов, формат — из поля format или по расширению),
+// сопоставляет её цвета с палитрой и возвращает схему в формате output (по умолчанию PDF).
+func ImportHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Разрешён только POST-запрос
+	if r.Method != http.MethodPost {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxPatternSize)
+
+	// 2. Определяем формат результата
+	format, err := export.ParseFormat(r.FormValue("output"))
+	if err != nil {
+		http.Error(w, "Некорректный формат результата", http.StatusBadRequest)
+		return
+	}
+
+	// 3. Получаем файл и определяем его формат
+	file, header, err := r.FormFile("pattern")
+	if err != nil {
+		http.Error(w, "Ошибка получения файла схемы", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	patternFormat, err := importer.DetectFormat(r.FormValue("format"), header.Filename)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 4. Импортируем и сопоставляем цвета с палитрой; коды, выпавшие из рабочей палитры,
+	// узнаются по полной
+	palette := Palettes.Load()
+	pattern, err := importer.Import(file, patternFormat, palette.Colors, palette.All)
+	if err != nil {
+		log.Printf("Ошибка импорта схемы: %v", err)
+		http.Error(w, fmt.Sprintf("Ошибка импорта схемы: %v", err), http.StatusBadRequest)
+		return
+	}
+	for _, s := range pattern.Substitutions {
+		log.Printf("Импорт: код %s заменён на DMC %s", s.SourceCode, s.Palette.DMCCode)
+	}
+	w.Header().Set("X-Import-Substitutions", strconv.Itoa(len(pattern.Substitutions)))
+
+	// 5. Рисуем схему и отправляем файл
+	writeScheme(w, format, image.ResultFromGrid(pattern.Matched, ProcessOptions), palette)
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"diamond-mosaic/internal/bom"
+	"diamond-mosaic/internal/db"
+	"diamond-mosaic/internal/export"
+	"diamond-mosaic/internal/image"
+)
+
+// KitSettings — параметры расчёта комплектации и стоимости набора из конфигурации сервера.
+var KitSettings = bom.DefaultSettings()
+
+// SetKitSettings устанавливает параметры комплектации для обработчиков.
+func SetKitSettings(s bom.Settings) {
+	KitSettings = s
+}
+
+// withKit считает комплектацию набора по ценам пакетиков из палитры palette.
+func withKit(res image.Result, palette *db.PaletteSnapshot) image.Result {
+	res.Kit = bom.Calculate(export.ColorCounts(res.Usages), res.Size.BaseWidthCM, res.Size.BaseHeightCM,
+		KitSettings, bom.Prices(palette.Colors))
+	return res
+}
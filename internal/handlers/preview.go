@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"image/png"
+	"log"
+	"net/http"
+	"strconv"
+
+	"diamond-mosaic/internal/export"
+	"diamond-mosaic/internal/image"
+)
+
+// preview — уменьшенная картинка мозаики и статистика по цветам.
+type preview struct {
+	PNG    []byte
+	Colors int
+	Drills int
+	Size   image.MosaicSizeInfo
+	Legend []export.LegendEntry
+
+	Quality image.QualityStats
+}
+
+// buildPreview подбирает цвета так же, как генерация схемы (та же сетка, что уйдёт
+// в RenderMosaic), но вместо схемы с символами рисует маленькую картинку без символов.
+// Готовые предпросмотры кешируются: при живом обновлении параметры часто возвращаются к прежним.
+func buildPreview(gen generation) (preview, error) {
+	key := gen.key(cacheKindPreview)
+	if data, ok := Results.Get(key); ok {
+		var p preview
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&p); err == nil {
+			return p, nil
+		}
+	}
+
+	res, err := image.MatchImage(bytes.NewReader(gen.data), gen.palette.Colors, gen.widthCm, gen.heightCm, gen.opts)
+	if err != nil {
+		return preview{}, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.RenderPreview(res.Matched, previewMaxSide)); err != nil {
+		return preview{}, fmt.Errorf("ошибка формирования предпросмотра: %w", err)
+	}
+
+	p := preview{PNG: buf.Bytes(), Size: res.Size, Legend: export.Legend(res.Usages)}
+	p.Quality, _ = image.Quality(res)
+	p.Colors = len(p.Legend)
+	for _, e := range p.Legend {
+		p.Drills += e.Count
+	}
+
+	var entry bytes.Buffer
+	if err := gob.NewEncoder(&entry).Encode(p); err == nil {
+		Results.Put(key, entry.Bytes())
+	}
+	return p, nil
+}
+
+// PreviewHandler обрабатывает POST-запрос /preview: принимает те же поля, что и /generate,
+// и быстро возвращает PNG-предпросмотр мозаики без символов. Число цветов, алмазов
+// и размер сетки передаются в заголовках X-Preview-Colors, X-Preview-Drills и X-Preview-Grid,
+// ошибка цветопередачи — в X-Quality-Mean-DeltaE и X-Quality-Max-DeltaE.
+func PreviewHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Разрешён только POST-запрос
+	if r.Method != http.MethodPost {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+
+	// 2. Получаем размеры основы и параметры генерации из формы
+	widthCm, heightCm, err := parseSizeCm(r.FormValue("width"), r.FormValue("height"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts, err := parseOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 3. Получаем загруженный файл
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Ошибка получения файла", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	gen, err := newGeneration(file, widthCm, heightCm, opts)
+	if err != nil {
+		http.Error(w, "Ошибка получения файла", http.StatusBadRequest)
+		return
+	}
+
+	// 4. Подбираем цвета и рисуем предпросмотр
+	p, err := buildPreview(gen)
+	if err != nil {
+		log.Printf("Ошибка предпросмотра: %v", err)
+		http.Error(w, fmt.Sprintf("Ошибка обработки изображения: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+
+	// 5. Отправляем картинку, статистику — в заголовках
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Preview-Colors", strconv.Itoa(p.Colors))
+	w.Header().Set("X-Preview-Drills", strconv.Itoa(p.Drills))
+	w.Header().Set("X-Preview-Grid", fmt.Sprintf("%dx%d", p.Size.BaseWidthPX, p.Size.BaseHeightPX))
+	setQualityHeaders(w, p.Quality)
+	w.Write(p.PNG)
+}
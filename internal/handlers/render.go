@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"diamond-mosaic/internal/export"
+	"diamond-mosaic/internal/scheme"
+)
+
+// maxSchemeSize — ограничение на размер загружаемого файла схемы.
+const maxSchemeSize = 64 << 20
+
+// RenderHandler обрабатывает POST-запрос /render: принимает сохранённый файл схемы
+// (поле scheme, .dmscheme) и заново формирует по нему файл в формате output.
+// Исходная фотография и текущая палитра не нужны — цвета и символы берутся из схемы.
+func RenderHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Разрешён только POST-запрос
+	if r.Method != http.MethodPost {
+		http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxSchemeSize)
+
+	// 2. Определяем формат результата
+	format, err := export.ParseFormat(r.FormValue("output"))
+	if err != nil {
+		http.Error(w, "Некорректный формат результата", http.StatusBadRequest)
+		return
+	}
+
+	// 3. Читаем файл схемы
+	file, _, err := r.FormFile("scheme")
+	if err != nil {
+		http.Error(w, "Ошибка получения файла схемы", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	s, err := scheme.Read(file)
+	if err != nil {
+		log.Printf("Ошибка чтения схемы: %v", err)
+		http.Error(w, fmt.Sprintf("Ошибка чтения схемы: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// 4. Перерисовываем схему и отправляем файл
+	writeScheme(w, format, s.Result(), Palettes.Load())
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// staticAsset — содержимое статического файла и его ETag.
+type staticAsset struct {
+	data []byte
+	etag string
+}
+
+// StaticHandler раздаёт файлы фронтенда из fsys.
+// Для встроенных файлов (dev == false) содержимое и ETag кешируются в памяти,
+// index.html всегда перепроверяется, а CSS/JS кешируются браузером на час.
+// В режиме разработки (dev == true) файлы читаются с диска на каждый запрос
+// и браузеру запрещено их кешировать.
+func StaticHandler(fsys fs.FS, dev bool) http.Handler {
+	var mu sync.Mutex
+	cache := map[string]staticAsset{}
+	started := time.Now()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Метод не поддерживается", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// 1. Приводим путь к имени файла внутри fsys
+		name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		if name == "" {
+			name = "index.html"
+		}
+
+		// 2. Берём файл из кеша или читаем его
+		mu.Lock()
+		asset, ok := cache[name]
+		mu.Unlock()
+		if !ok {
+			data, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			sum := sha256.Sum256(data)
+			asset = staticAsset{data: data, etag: `"` + hex.EncodeToString(sum[:8]) + `"`}
+			if !dev {
+				mu.Lock()
+				cache[name] = asset
+				mu.Unlock()
+			}
+		}
+
+		// 3. Заголовки кеширования
+		switch {
+		case dev:
+			w.Header().Set("Cache-Control", "no-store")
+		case name == "index.html":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", asset.etag)
+		default:
+			w.Header().Set("Cache-Control", "public, max-age=3600")
+			w.Header().Set("ETag", asset.etag)
+		}
+
+		// 4. ServeContent сам выставит Content-Type и обработает If-None-Match
+		modtime := started
+		if dev {
+			modtime = time.Time{} // без Last-Modified: файл мог измениться на диске
+		}
+		http.ServeContent(w, r, name, modtime, bytes.NewReader(asset.data))
+
+	})
+}
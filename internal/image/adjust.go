@@ -0,0 +1,159 @@
+package image
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/disintegration/imaging"
+)
+
+// Ограничения коррекции изображения.
+const (
+	maxAdjustPercent = 100 // яркость, контраст и насыщенность: от -100 до 100 %
+	minGamma         = 0.2 // гамма: от minGamma до maxGamma, 0 — без изменений
+	maxGamma         = 5
+	levelsClip       = .005 // доля самых тёмных и светлых значений, не учитываемая автоуровнями
+	maxBalanceGain   = 2    // наибольшее усиление канала при балансе белого (и 1/maxBalanceGain — ослабление)
+)
+
+// Adjustments — коррекция изображения перед подбором цветов: тёмные и блёклые фотографии
+// без неё дают тусклую схему. Нулевое значение ничего не меняет.
+type Adjustments struct {
+	WhiteBalance bool    `json:"white_balance"` // баланс белого по среднему серому (gray world)
+	AutoLevels   bool    `json:"auto_levels"`   // растянуть диапазон яркостей на 0–255
+	Brightness   float64 `json:"brightness"`    // яркость, −100…100 %
+	Contrast     float64 `json:"contrast"`      // контраст, −100…100 %
+	Gamma        float64 `json:"gamma"`         // гамма: больше 1 — светлее средние тона, 0 — без изменений
+	Saturation   float64 `json:"saturation"`    // насыщенность, −100…100 %
+}
+
+// Validate проверяет параметры коррекции.
+func (a Adjustments) Validate() error {
+	for _, v := range []struct {
+		name  string
+		value float64
+	}{{"brightness", a.Brightness}, {"contrast", a.Contrast}, {"saturation", a.Saturation}} {
+		if v.value < -maxAdjustPercent || v.value > maxAdjustPercent {
+			return fmt.Errorf("%s должен быть от -%d до %d", v.name, maxAdjustPercent, maxAdjustPercent)
+		}
+	}
+	if a.Gamma != 0 && (a.Gamma < minGamma || a.Gamma > maxGamma) {
+		return fmt.Errorf("gamma должна быть 0 или от %g до %d", minGamma, maxGamma)
+	}
+	return nil
+}
+
+// Apply применяет коррекцию к изображению по порядку: баланс белого, автоуровни, яркость,
+// контраст, гамма, насыщенность. Без коррекции img возвращается как есть.
+func (a Adjustments) Apply(img image.Image) image.Image {
+	if a == (Adjustments{}) {
+		return img
+	}
+	dst := imaging.Clone(img)
+
+	// 1. Баланс белого и автоуровни считаются по самому изображению
+	if a.WhiteBalance {
+		whiteBalance(dst)
+	}
+	if a.AutoLevels {
+		autoLevels(dst)
+	}
+
+	// 2. Ручная коррекция
+	if a.Brightness != 0 {
+		dst = imaging.AdjustBrightness(dst, a.Brightness)
+	}
+	if a.Contrast != 0 {
+		dst = imaging.AdjustContrast(dst, a.Contrast)
+	}
+	if a.Gamma != 0 && a.Gamma != 1 {
+		dst = imaging.AdjustGamma(dst, a.Gamma)
+	}
+	if a.Saturation != 0 {
+		dst = imaging.AdjustSaturation(dst, a.Saturation)
+	}
+	return dst
+}
+
+// whiteBalance выравнивает средние значения каналов в линейном свете (гипотеза «мир в среднем серый»):
+// каждый канал умножается на отношение среднего серого к своему среднему.
+func whiteBalance(img *image.NRGBA) {
+	// 1. Средние каналов в линейном свете
+	var sum [3]float64
+	for i := 0; i < len(img.Pix); i += 4 {
+		for c := 0; c < 3; c++ {
+			sum[c] += srgbToLinear[img.Pix[i+c]]
+		}
+	}
+	gray := (sum[0] + sum[1] + sum[2]) / 3
+	if gray == 0 {
+		return // чёрное изображение
+	}
+
+	// 2. Таблицы пересчёта каналов с ограниченным усилением
+	var lut [3][256]uint8
+	for c := range lut {
+		gain := 1.0
+		if sum[c] > 0 {
+			gain = gray / sum[c]
+		}
+		if gain > maxBalanceGain {
+			gain = maxBalanceGain
+		} else if gain < 1.0/maxBalanceGain {
+			gain = 1.0 / maxBalanceGain
+		}
+		for v := range lut[c] {
+			lut[c][v] = linearToSRGB(srgbToLinear[v] * gain)
+		}
+	}
+	for i := 0; i < len(img.Pix); i += 4 {
+		for c := 0; c < 3; c++ {
+			img.Pix[i+c] = lut[c][img.Pix[i+c]]
+		}
+	}
+}
+
+// autoLevels растягивает значения всех каналов так, чтобы самые тёмные (без levelsClip) стали 0,
+// а самые светлые — 255. Каналы растягиваются одинаково, поэтому оттенки не сдвигаются.
+func autoLevels(img *image.NRGBA) {
+	// 1. Гистограмма по всем каналам
+	var hist [256]int
+	n := 0
+	for i := 0; i < len(img.Pix); i += 4 {
+		hist[img.Pix[i]]++
+		hist[img.Pix[i+1]]++
+		hist[img.Pix[i+2]]++
+		n += 3
+	}
+
+	// 2. Границы диапазона без крайних значений
+	clip := int(float64(n) * levelsClip)
+	lo, hi := 0, 255
+	for seen := hist[lo]; seen <= clip && lo < 255; seen += hist[lo] {
+		lo++
+	}
+	for seen := hist[hi]; seen <= clip && hi > 0; seen += hist[hi] {
+		hi--
+	}
+	if hi-lo < 2 || lo == 0 && hi == 255 {
+		return // однотонное изображение или диапазон уже полный
+	}
+
+	// 3. Линейное растяжение
+	var lut [256]uint8
+	for v := range lut {
+		switch {
+		case v <= lo:
+			lut[v] = 0
+		case v >= hi:
+			lut[v] = 255
+		default:
+			lut[v] = uint8(((v-lo)*255 + (hi-lo)/2) / (hi - lo))
+		}
+	}
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i] = lut[img.Pix[i]]
+		img.Pix[i+1] = lut[img.Pix[i+1]]
+		img.Pix[i+2] = lut[img.Pix[i+2]]
+	}
+}
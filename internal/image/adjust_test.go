@@ -0,0 +1,135 @@
+package image
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+// grayRamp создаёт изображение в одну строку с серыми уровнями от lo до hi включительно.
+func grayRamp(lo, hi int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, hi-lo+1, 1))
+	for v := lo; v <= hi; v++ {
+		img.SetNRGBA(v-lo, 0, color.NRGBA{R: uint8(v), G: uint8(v), B: uint8(v), A: 255})
+	}
+	return img
+}
+
+func TestAdjustmentsValidate(t *testing.T) {
+	for _, a := range []Adjustments{{}, {Brightness: -100, Contrast: 100, Saturation: 50, Gamma: minGamma}, {Gamma: maxGamma}} {
+		if err := a.Validate(); err != nil {
+			t.Errorf("%+v: неожиданная ошибка %v", a, err)
+		}
+	}
+	for _, a := range []Adjustments{{Brightness: 101}, {Contrast: -101}, {Saturation: 200}, {Gamma: 0.1}, {Gamma: 6}} {
+		if a.Validate() == nil {
+			t.Errorf("%+v: ожидалась ошибка", a)
+		}
+	}
+}
+
+func TestAdjustmentsApplyZero(t *testing.T) {
+	img := uniformImage(2, 2, color.NRGBA{R: 10, G: 20, B: 30, A: 255})
+	if got := (Adjustments{}).Apply(img); got != image.Image(img) {
+		t.Error("без коррекции ожидалось то же изображение")
+	}
+}
+
+func TestWhiteBalance(t *testing.T) {
+	// Серое изображение не меняется
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for i := 0; i < len(img.Pix); i += 4 {
+		v := uint8(rng.Intn(256))
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = v, v, v, uint8(rng.Intn(256))
+	}
+	want := append([]uint8(nil), img.Pix...)
+	whiteBalance(img)
+	for i := range want {
+		if img.Pix[i] != want[i] {
+			t.Fatalf("серое изображение: байт %d = %d, ожидалось %d", i, img.Pix[i], want[i])
+		}
+	}
+
+	// Чёрное изображение не меняется
+	black := uniformImage(2, 2, color.NRGBA{A: 255})
+	whiteBalance(black)
+	if c := nrgbaAt(black, 0, 0); c != (color.NRGBA{A: 255}) {
+		t.Errorf("чёрное изображение: %v", c)
+	}
+
+	// Слабый оттенок убирается полностью: каналы сходятся к среднему серому
+	tinted := uniformImage(2, 2, color.NRGBA{R: 150, G: 120, B: 120, A: 200})
+	whiteBalance(tinted)
+	c := nrgbaAt(tinted, 1, 1)
+	if absDiff(c.R, c.G) > 1 || absDiff(c.G, c.B) > 1 || c.A != 200 {
+		t.Errorf("оттенок не убран: %v", c)
+	}
+	if c.G <= 120 || c.R >= 150 {
+		t.Errorf("каналы %v должны сойтись между 120 и 150", c)
+	}
+
+	// Сильный оттенок: усиление ограничено maxBalanceGain
+	strong := uniformImage(2, 2, color.NRGBA{R: 200, G: 50, B: 50, A: 255})
+	whiteBalance(strong)
+	wantR := linearToSRGB(srgbToLinear[200] / maxBalanceGain)
+	wantG := linearToSRGB(srgbToLinear[50] * maxBalanceGain)
+	if c := nrgbaAt(strong, 0, 0); c.R != wantR || c.G != wantG || c.B != wantG {
+		t.Errorf("сильный оттенок: %v, ожидалось R=%d, G=B=%d", c, wantR, wantG)
+	}
+}
+
+func TestAutoLevels(t *testing.T) {
+	// Диапазон 50–200 растягивается на 0–255, середина остаётся серединой
+	img := grayRamp(50, 200)
+	autoLevels(img)
+	for x, want := range map[int]uint8{0: 0, 75: 128, 150: 255} {
+		if c := nrgbaAt(img, x, 0); c.R != want || c.G != want || c.B != want {
+			t.Errorf("пиксель %d = %v, ожидалось %d", x, c, want)
+		}
+	}
+	for x := 1; x < img.Bounds().Dx(); x++ {
+		if img.Pix[4*x] < img.Pix[4*(x-1)] {
+			t.Fatalf("растяжение не монотонно в пикселе %d", x)
+		}
+	}
+
+	// Единичные выбросы отсекаются levelsClip и не мешают растяжению
+	img = image.NewNRGBA(image.Rect(0, 0, 100, 10))
+	for i := 0; i < len(img.Pix); i += 4 {
+		v := uint8(100 + i/4%2*50) // 100 и 150 поровну
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = v, v, v, 255
+	}
+	img.SetNRGBA(0, 0, color.NRGBA{A: 255})
+	img.SetNRGBA(1, 0, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+	autoLevels(img)
+	if lo, hi := nrgbaAt(img, 2, 0), nrgbaAt(img, 3, 0); lo.R != 0 || hi.R != 255 {
+		t.Errorf("100 и 150 стали %d и %d, ожидалось 0 и 255", lo.R, hi.R)
+	}
+
+	// Каналы растягиваются одинаково
+	img = grayRamp(50, 200)
+	img.SetNRGBA(10, 0, color.NRGBA{R: 200, G: 125, B: 50, A: 255})
+	autoLevels(img)
+	if c := nrgbaAt(img, 10, 0); c.R != 255 || c.G != 128 || c.B != 0 {
+		t.Errorf("цветной пиксель %v, ожидалось {255 128 0}", c)
+	}
+
+	// Полный диапазон и однотонное изображение не меняются
+	full := grayRamp(0, 2)
+	full.Pix[8], full.Pix[9], full.Pix[10] = 255, 255, 255 // 0, 1 и 255
+	for name, img := range map[string]*image.NRGBA{
+		"полный диапазон": full,
+		"однотонное":      uniformImage(4, 4, color.NRGBA{R: 90, G: 90, B: 90, A: 255}),
+	} {
+		want := append([]uint8(nil), img.Pix...)
+		autoLevels(img)
+		for i := range want {
+			if img.Pix[i] != want[i] {
+				t.Errorf("%s: байт %d = %d, ожидалось %d", name, i, img.Pix[i], want[i])
+				break
+			}
+		}
+	}
+}
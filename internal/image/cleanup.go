@@ -0,0 +1,257 @@
+package image
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"sort"
+	"time"
+
+	"diamond-mosaic/internal/db"
+)
+
+// CleanupMethod — способ удаления мелких островков на сетке подобранных цветов.
+type CleanupMethod string
+
+const (
+	CleanupMerge    CleanupMethod = "merge"    // островок целиком получает ближайший по Lab соседний цвет
+	CleanupMajority CleanupMethod = "majority" // каждая клетка островка получает самый частый цвет соседей
+)
+
+// Ограничения очистки.
+const (
+	maxMinIsland     = 50 // наибольший порог размера островка
+	maxCleanupPasses = 10 // наибольшее число проходов: после перекраски могут появиться новые островки
+)
+
+// ParseCleanupMethod разбирает способ очистки; пустая строка — CleanupMerge.
+func ParseCleanupMethod(s string) (CleanupMethod, error) {
+	switch m := CleanupMethod(s); m {
+	case "":
+		return CleanupMerge, nil
+	case CleanupMerge, CleanupMajority:
+		return m, nil
+	default:
+		return "", fmt.Errorf("неизвестный способ очистки %q (merge, majority)", s)
+	}
+}
+
+// Cleanup — очистка схемы от островков: связных по сторонам областей одного цвета,
+// в которых меньше MinIsland клеток. Одиночные алмазы сильно замедляют сборку.
+type Cleanup struct {
+	Method    CleanupMethod `json:"method"`     // способ очистки, пусто — merge
+	MinIsland int           `json:"min_island"` // сила: островки меньше стольких клеток удаляются, 0 — выключено
+}
+
+// DefaultCleanup возвращает очистку по умолчанию: убираются только одиночные алмазы.
+func DefaultCleanup() Cleanup {
+	return Cleanup{Method: CleanupMerge, MinIsland: 2}
+}
+
+// Validate проверяет параметры очистки.
+func (c Cleanup) Validate() error {
+	if _, err := ParseCleanupMethod(string(c.Method)); err != nil {
+		return err
+	}
+	if c.MinIsland < 0 || c.MinIsland > maxMinIsland {
+		return fmt.Errorf("min_island должен быть от 0 до %d", maxMinIsland)
+	}
+	return nil
+}
+
+// CleanupIslands перекрашивает островки меньше c.MinIsland клеток в соседние цвета; сетка
+// matched меняется на месте. Пустые клетки (BLANK) не меняются и соседями не считаются,
+// поэтому островок, окружённый только фоном, остаётся. Возвращает число перекрашенных клеток.
+func CleanupIslands(matched [][]db.PaletteColor, c Cleanup) int {
+	if c.MinIsland < 2 {
+		return 0
+	}
+	start := time.Now() // замер времени выполнения
+
+	changed := 0
+	for pass := 0; pass < maxCleanupPasses; pass++ {
+		islands := findIslands(matched, c.MinIsland)
+		if len(islands) == 0 {
+			break
+		}
+		var n int
+		if c.Method == CleanupMajority {
+			n = majorityPass(matched, islands)
+		} else {
+			n = mergePass(matched, islands)
+		}
+		if n == 0 {
+			break
+		}
+		changed += n
+	}
+
+	elapsed := time.Since(start)
+	log.Printf("[CleanupIslands] Перекрашено клеток: %d, время выполнения: %s", changed, elapsed)
+	return changed
+}
+
+// side — соседи клетки по сторонам; вместе с диагоналями — neighbours8.
+var (
+	side        = [4]image.Point{{X: 1}, {X: -1}, {Y: 1}, {Y: -1}}
+	neighbours8 = [8]image.Point{{X: 1}, {X: -1}, {Y: 1}, {Y: -1}, {X: 1, Y: 1}, {X: 1, Y: -1}, {X: -1, Y: 1}, {X: -1, Y: -1}}
+)
+
+// findIslands находит связные по сторонам области одного цвета меньше minSize клеток,
+// от меньших к большим.
+func findIslands(matched [][]db.PaletteColor, minSize int) [][]image.Point {
+	h := len(matched)
+	if h == 0 {
+		return nil
+	}
+	w := len(matched[0])
+	visited := make([]bool, w*h)
+
+	var islands [][]image.Point
+	var stack, region []image.Point
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if visited[y*w+x] || matched[y][x].DMCCode == "BLANK" {
+				continue
+			}
+
+			// Обходим область в глубину; большие области обходятся целиком, но не запоминаются
+			code := matched[y][x].DMCCode
+			visited[y*w+x] = true
+			stack = append(stack[:0], image.Point{X: x, Y: y})
+			region = region[:0]
+			size := 0
+			for len(stack) > 0 {
+				p := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				size++
+				if size < minSize {
+					region = append(region, p)
+				}
+				for _, d := range side {
+					q := p.Add(d)
+					if q.X < 0 || q.X >= w || q.Y < 0 || q.Y >= h || visited[q.Y*w+q.X] || matched[q.Y][q.X].DMCCode != code {
+						continue
+					}
+					visited[q.Y*w+q.X] = true
+					stack = append(stack, q)
+				}
+			}
+			if size < minSize {
+				islands = append(islands, append([]image.Point(nil), region...))
+			}
+		}
+	}
+	sort.SliceStable(islands, func(i, j int) bool { return len(islands[i]) < len(islands[j]) })
+	return islands
+}
+
+// mergePass перекрашивает каждый островок целиком в ближайший по Lab цвет, граничащий с ним.
+// Островки обрабатываются от меньших к большим, уже перекрашенные соседи учитываются.
+func mergePass(matched [][]db.PaletteColor, islands [][]image.Point) int {
+	h, w := len(matched), len(matched[0])
+	changed := 0
+	for _, island := range islands {
+		own := matched[island[0].Y][island[0].X]
+
+		// 1. Соседние цвета и длина границы с каждым
+		border := map[string]int{}
+		colors := map[string]db.PaletteColor{}
+		merged := false // островок уже слился с перекрашенным соседом своего цвета
+		for _, p := range island {
+			for _, d := range side {
+				q := p.Add(d)
+				if q.X < 0 || q.X >= w || q.Y < 0 || q.Y >= h {
+					continue
+				}
+				pc := matched[q.Y][q.X]
+				if pc.DMCCode == "BLANK" {
+					continue
+				}
+				if pc.DMCCode == own.DMCCode {
+					if !containsPoint(island, q) {
+						merged = true
+					}
+					continue
+				}
+				border[pc.DMCCode]++
+				colors[pc.DMCCode] = pc
+			}
+		}
+		if merged || len(border) == 0 {
+			continue
+		}
+
+		// 2. Ближайший по Lab соседний цвет; при равенстве — с более длинной границей, затем по коду
+		var target db.PaletteColor
+		best := -1.0
+		for code, pc := range colors {
+			d := labDistance(own.Color, pc.Color)
+			if best < 0 || d < best ||
+				d == best && (border[code] > border[target.DMCCode] || border[code] == border[target.DMCCode] && code < target.DMCCode) {
+				best, target = d, pc
+			}
+		}
+		for _, p := range island {
+			matched[p.Y][p.X] = target
+		}
+		changed += len(island)
+	}
+	return changed
+}
+
+// majorityPass перекрашивает каждую клетку островков в самый частый среди восьми соседей
+// другой цвет (при равенстве — ближайший по Lab). Новые цвета считаются по сетке до прохода.
+func majorityPass(matched [][]db.PaletteColor, islands [][]image.Point) int {
+	h, w := len(matched), len(matched[0])
+	type recolor struct {
+		p  image.Point
+		pc db.PaletteColor
+	}
+	var updates []recolor
+	for _, island := range islands {
+		for _, p := range island {
+			own := matched[p.Y][p.X]
+			votes := map[string]int{}
+			colors := map[string]db.PaletteColor{}
+			for _, d := range neighbours8 {
+				q := p.Add(d)
+				if q.X < 0 || q.X >= w || q.Y < 0 || q.Y >= h {
+					continue
+				}
+				pc := matched[q.Y][q.X]
+				if pc.DMCCode == "BLANK" || pc.DMCCode == own.DMCCode {
+					continue
+				}
+				votes[pc.DMCCode]++
+				colors[pc.DMCCode] = pc
+			}
+			if len(votes) == 0 {
+				continue
+			}
+			var target db.PaletteColor
+			best, bestDist := 0, 0.0
+			for code, pc := range colors {
+				d := labDistance(own.Color, pc.Color)
+				if votes[code] > best || votes[code] == best && (d < bestDist || d == bestDist && code < target.DMCCode) {
+					best, bestDist, target = votes[code], d, pc
+				}
+			}
+			updates = append(updates, recolor{p: p, pc: target})
+		}
+	}
+	for _, u := range updates {
+		matched[u.p.Y][u.p.X] = u.pc
+	}
+	return len(updates)
+}
+
+// containsPoint сообщает, входит ли клетка p в островок.
+func containsPoint(island []image.Point, p image.Point) bool {
+	for _, q := range island {
+		if q == p {
+			return true
+		}
+	}
+	return false
+}
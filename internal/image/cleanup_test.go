@@ -0,0 +1,233 @@
+package image
+
+import (
+	"image"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"diamond-mosaic/internal/db"
+)
+
+// cleanupColors — серые уровни цветов тестовых сеток: B ближе к C и D, чем к A.
+var cleanupColors = map[byte]float64{'A': 0.1, 'B': 0.5, 'C': 0.45, 'D': 0.55}
+
+// cleanupGrid строит сетку из строк: буква — код цвета из cleanupColors, точка — BLANK.
+func cleanupGrid(rows ...string) [][]db.PaletteColor {
+	grid := make([][]db.PaletteColor, len(rows))
+	for y, row := range rows {
+		for x := 0; x < len(row); x++ {
+			if row[x] == '.' {
+				grid[y] = append(grid[y], BlankColor())
+			} else {
+				grid[y] = append(grid[y], testColor(string(row[x]), cleanupColors[row[x]]))
+			}
+		}
+	}
+	return grid
+}
+
+// gridRows возвращает сетку в виде строк, как у cleanupGrid.
+func gridRows(grid [][]db.PaletteColor) []string {
+	rows := make([]string, len(grid))
+	for y, row := range grid {
+		var sb strings.Builder
+		for _, pc := range row {
+			if pc.DMCCode == "BLANK" {
+				sb.WriteByte('.')
+			} else {
+				sb.WriteString(pc.DMCCode)
+			}
+		}
+		rows[y] = sb.String()
+	}
+	return rows
+}
+
+func TestParseCleanupMethod(t *testing.T) {
+	for in, want := range map[string]CleanupMethod{"": CleanupMerge, "merge": CleanupMerge, "majority": CleanupMajority} {
+		if got, err := ParseCleanupMethod(in); err != nil || got != want {
+			t.Errorf("ParseCleanupMethod(%q) = %q, %v, ожидалось %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseCleanupMethod("blur"); err == nil {
+		t.Error("ожидалась ошибка для неизвестного способа")
+	}
+}
+
+func TestCleanupValidate(t *testing.T) {
+	if c := DefaultCleanup(); c.Method != CleanupMerge || c.MinIsland != 2 || c.Validate() != nil {
+		t.Errorf("DefaultCleanup() = %+v, ожидалось merge и min_island 2", c)
+	}
+	for _, c := range []Cleanup{{MinIsland: 0}, {Method: CleanupMajority, MinIsland: maxMinIsland}} {
+		if err := c.Validate(); err != nil {
+			t.Errorf("%+v: неожиданная ошибка %v", c, err)
+		}
+	}
+	for _, c := range []Cleanup{{MinIsland: -1}, {MinIsland: maxMinIsland + 1}, {Method: "blur", MinIsland: 2}} {
+		if c.Validate() == nil {
+			t.Errorf("%+v: ожидалась ошибка", c)
+		}
+	}
+}
+
+func TestFindIslands(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    []string
+		minSize int
+		want    [][]image.Point
+	}{
+		{
+			name:    "одиночная клетка",
+			rows:    []string{"AAA", "ABA", "AAA"},
+			minSize: 2,
+			want:    [][]image.Point{{{X: 1, Y: 1}}},
+		},
+		{
+			name:    "диагональ не связывает",
+			rows:    []string{"AB", "BA"},
+			minSize: 2,
+			want:    [][]image.Point{{{X: 0, Y: 0}}, {{X: 1, Y: 0}}, {{X: 0, Y: 1}}, {{X: 1, Y: 1}}},
+		},
+		{
+			name:    "область ровно minSize — не островок",
+			rows:    []string{"AAB", "AAB", "AAA"},
+			minSize: 2,
+		},
+		{
+			name:    "область на клетку меньше minSize",
+			rows:    []string{"AAB", "AAB", "AAA"},
+			minSize: 3,
+			want:    [][]image.Point{{{X: 2, Y: 0}, {X: 2, Y: 1}}},
+		},
+		{
+			name:    "от меньших к большим",
+			rows:    []string{"CCB", "AAA"},
+			minSize: 4,
+			want:    [][]image.Point{{{X: 2, Y: 0}}, {{X: 0, Y: 0}, {X: 1, Y: 0}}, {{X: 0, Y: 1}, {X: 1, Y: 1}, {X: 2, Y: 1}}},
+		},
+		{
+			name:    "пустые клетки пропускаются",
+			rows:    []string{"A..", "..."},
+			minSize: 2,
+			want:    [][]image.Point{{{X: 0, Y: 0}}},
+		},
+		{
+			name:    "пустая сетка",
+			minSize: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := findIslands(cleanupGrid(tt.rows...), tt.minSize)
+			for _, island := range got {
+				sortPoints(island)
+			}
+			if len(got) != len(tt.want) || len(got) > 0 && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("островки %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// sortPoints упорядочивает клетки островка построчно: обход в глубину даёт их в другом порядке.
+func sortPoints(ps []image.Point) {
+	sort.Slice(ps, func(i, j int) bool { return ps[i].Y < ps[j].Y || ps[i].Y == ps[j].Y && ps[i].X < ps[j].X })
+}
+
+func TestCleanupIslands(t *testing.T) {
+	tests := []struct {
+		name    string
+		cleanup Cleanup
+		rows    []string
+		want    []string
+		changed int
+	}{
+		{
+			name:    "выключено",
+			cleanup: Cleanup{Method: CleanupMerge, MinIsland: 0},
+			rows:    []string{"AAA", "ABA", "AAA"},
+			want:    []string{"AAA", "ABA", "AAA"},
+		},
+		{
+			name:    "min_island 1 ничего не убирает",
+			cleanup: Cleanup{Method: CleanupMajority, MinIsland: 1},
+			rows:    []string{"AAA", "ABA", "AAA"},
+			want:    []string{"AAA", "ABA", "AAA"},
+		},
+		{
+			name:    "по умолчанию убирается одиночный алмаз",
+			cleanup: DefaultCleanup(),
+			rows:    []string{"AAA", "ABA", "AAA"},
+			want:    []string{"AAA", "AAA", "AAA"},
+			changed: 1,
+		},
+		{
+			name:    "merge: ближайший по Lab сосед, а не самый длинный край",
+			cleanup: Cleanup{Method: CleanupMerge, MinIsland: 2},
+			rows:    []string{"AAAAA", "DDBAA", "AAAAA"},
+			want:    []string{"AAAAA", "DDDAA", "AAAAA"},
+			changed: 1,
+		},
+		{
+			name:    "merge: островок перекрашивается целиком",
+			cleanup: Cleanup{Method: CleanupMerge, MinIsland: 3},
+			rows:    []string{"AAAA", "ABBA", "AAAA"},
+			want:    []string{"AAAA", "AAAA", "AAAA"},
+			changed: 2,
+		},
+		{
+			name:    "merge: область ровно min_island остаётся",
+			cleanup: Cleanup{Method: CleanupMerge, MinIsland: 2},
+			rows:    []string{"AAAA", "ABBA", "AAAA"},
+			want:    []string{"AAAA", "ABBA", "AAAA"},
+		},
+		{
+			name:    "merge: островок сливается с уже перекрашенным соседом",
+			cleanup: Cleanup{Method: CleanupMerge, MinIsland: 2},
+			rows:    []string{"AAA", "ABC", "AAA"},
+			want:    []string{"AAA", "ACC", "AAA"},
+			changed: 1,
+		},
+		{
+			name:    "majority: цвета по сетке до прохода",
+			cleanup: Cleanup{Method: CleanupMajority, MinIsland: 2},
+			rows:    []string{"AAA", "ABC", "AAA"},
+			want:    []string{"AAA", "AAA", "AAA"},
+			changed: 2,
+		},
+		{
+			name:    "majority: пустые клетки не голосуют",
+			cleanup: Cleanup{Method: CleanupMajority, MinIsland: 2},
+			rows:    []string{"...", ".B.", "AAA"},
+			want:    []string{"...", ".A.", "AAA"},
+			changed: 1,
+		},
+		{
+			name:    "merge: островок среди фона остаётся",
+			cleanup: Cleanup{Method: CleanupMerge, MinIsland: 2},
+			rows:    []string{"...", ".B.", "..."},
+			want:    []string{"...", ".B.", "..."},
+		},
+		{
+			name:    "majority: островок среди фона остаётся",
+			cleanup: Cleanup{Method: CleanupMajority, MinIsland: 2},
+			rows:    []string{"...", ".B.", "..."},
+			want:    []string{"...", ".B.", "..."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			grid := cleanupGrid(tt.rows...)
+			changed := CleanupIslands(grid, tt.cleanup)
+			if got := gridRows(grid); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("сетка %v, ожидалось %v", got, tt.want)
+			}
+			if changed != tt.changed {
+				t.Errorf("перекрашено %d, ожидалось %d", changed, tt.changed)
+			}
+		})
+	}
+}
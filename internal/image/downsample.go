@@ -0,0 +1,174 @@
+package image
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+// CellStat — как цвет клетки получается из всех пикселей исходника, попавших в неё
+// (при масштабировании ResampleArea).
+type CellStat string
+
+const (
+	CellMean    CellStat = "mean"    // среднее в линейном свете
+	CellMedian  CellStat = "median"  // медиана по каждому каналу: не замечает шум, блики и тонкие штрихи
+	CellTrimmed CellStat = "trimmed" // среднее в линейном свете без trimFraction крайних значений с каждой стороны
+)
+
+// trimFraction — доля самых тёмных и самых светлых значений канала, отбрасываемая CellTrimmed.
+const trimFraction = 0.1
+
+// ParseCellStat разбирает способ вычисления цвета клетки; пустая строка — CellMean.
+func ParseCellStat(s string) (CellStat, error) {
+	switch c := CellStat(s); c {
+	case "":
+		return CellMean, nil
+	case CellMean, CellMedian, CellTrimmed:
+		return c, nil
+	default:
+		return "", fmt.Errorf("неизвестный способ вычисления цвета клетки %q (mean, median, trimmed)", s)
+	}
+}
+
+// srgbToLinear переводит 8-битную компоненту sRGB в линейный свет (0–1).
+var srgbToLinear = func() (lut [256]float64) {
+	for i := range lut {
+		v := float64(i) / 255
+		if v <= 0.04045 {
+			lut[i] = v / 12.92
+		} else {
+			lut[i] = math.Pow((v+0.055)/1.055, 2.4)
+		}
+	}
+	return lut
+}()
+
+// linearToSRGB переводит компоненту из линейного света обратно в 8-битную sRGB.
+func linearToSRGB(v float64) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 1:
+		return 255
+	case v <= 0.0031308:
+		v *= 12.92
+	default:
+		v = 1.055*math.Pow(v, 1/2.4) - 0.055
+	}
+	return uint8(math.Round(v * 255))
+}
+
+// AreaDownsample уменьшает изображение до w×h: цвет каждой клетки вычисляется по всем
+// пикселям исходника, попавшим в неё, способом stat. Усреднение ведётся в линейном свете —
+// мелкий контрастный узор даёт тот цвет, который глаз видит издали (среднее в sRGB темнее).
+// Если исходник меньше сетки, клетка берёт цвет ближайшего пикселя. Полупрозрачные пиксели
+// берутся с цветом, умноженным на альфу (как в pixelColor): прозрачное даёт чёрный,
+// а результат непрозрачен.
+func AreaDownsample(src image.Image, w, h int, stat CellStat) *image.NRGBA {
+	start := time.Now() // замер времени выполнения
+
+	img := toNRGBA(src)
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+
+	var wg sync.WaitGroup
+	for y := 0; y < h; y++ {
+		wg.Add(1)
+		go func(y int) {
+			defer wg.Done()
+			y0, y1 := cellSpan(y, h, srcH)
+			var hist [3][256]int // гистограммы каналов клетки
+			for x := 0; x < w; x++ {
+				x0, x1 := cellSpan(x, w, srcW)
+				hist = [3][256]int{}
+				for sy := y0; sy < y1; sy++ {
+					off := img.PixOffset(bounds.Min.X+x0, bounds.Min.Y+sy)
+					for sx := x0; sx < x1; sx++ {
+						p := img.Pix[off : off+4 : off+4]
+						if a := p[3]; a == 255 {
+							hist[0][p[0]]++
+							hist[1][p[1]]++
+							hist[2][p[2]]++
+						} else {
+							hist[0][premultiply(p[0], a)]++
+							hist[1][premultiply(p[1], a)]++
+							hist[2][premultiply(p[2], a)]++
+						}
+						off += 4
+					}
+				}
+				n := (x1 - x0) * (y1 - y0)
+				o := dst.PixOffset(x, y)
+				for c := range hist {
+					dst.Pix[o+c] = linearToSRGB(cellValue(&hist[c], n, stat))
+				}
+				dst.Pix[o+3] = 255
+			}
+		}(y)
+	}
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	log.Printf("[AreaDownsample] Время выполнения: %s", elapsed)
+	return dst
+}
+
+// premultiply умножает 8-битную компоненту v на альфу a так же, как color.NRGBA.RGBA()
+// (старший байт результата), то есть как pixelColor.
+func premultiply(v, a uint8) uint8 {
+	if a == 255 {
+		return v
+	}
+	return uint8(uint32(v) * 0x101 * uint32(a) / 0xff >> 8)
+}
+
+// cellSpan возвращает диапазон пикселей [from, to) исходника размером size,
+// попадающий в клетку i из n; клетка получает хотя бы один пиксель.
+func cellSpan(i, n, size int) (from, to int) {
+	from = i * size / n
+	to = (i + 1) * size / n
+	if to <= from {
+		to = from + 1
+	}
+	return from, to
+}
+
+// cellValue сводит канал клетки по его гистограмме hist (n значений) в одно значение
+// в линейном свете способом stat. Компоненты 8-битные, поэтому гистограмма даёт точный результат
+// без сортировки.
+func cellValue(hist *[256]int, n int, stat CellStat) float64 {
+	// Берём значения с порядковыми номерами [from, to) в порядке возрастания
+	from, to := 0, n
+	switch stat {
+	case CellMedian:
+		from, to = (n-1)/2, n/2+1 // одно среднее значение или два при чётном n
+	case CellTrimmed:
+		k := int(float64(n) * trimFraction)
+		from, to = k, n-k
+	}
+
+	sum := 0.0
+	rank := 0
+	for v, count := range hist {
+		lo, hi := rank, rank+count
+		rank = hi
+		if lo < from {
+			lo = from
+		}
+		if hi > to {
+			hi = to
+		}
+		if hi > lo {
+			sum += float64(hi-lo) * srgbToLinear[v]
+		}
+		if rank >= to {
+			break
+		}
+	}
+	return sum / float64(to-from)
+}
@@ -0,0 +1,38 @@
+package image
+
+import (
+	"image/color"
+	"testing"
+)
+
+// TestAreaDownsampleAlpha проверяет, что полупрозрачные пиксели берутся с цветом,
+// умноженным на альфу, как в pixelColor, а результат непрозрачен.
+func TestAreaDownsampleAlpha(t *testing.T) {
+	tests := []struct {
+		name string
+		in   color.NRGBA
+		want color.NRGBA
+	}{
+		{"непрозрачный", color.NRGBA{R: 200, G: 100, B: 50, A: 255}, color.NRGBA{R: 200, G: 100, B: 50, A: 255}},
+		{"полупрозрачный", color.NRGBA{R: 200, G: 100, B: 50, A: 128}, color.NRGBA{R: 100, G: 50, B: 25, A: 255}},
+		{"прозрачный", color.NRGBA{R: 200, G: 100, B: 50, A: 0}, color.NRGBA{A: 255}},
+	}
+	for _, tt := range tests {
+		for _, stat := range []CellStat{CellMean, CellMedian, CellTrimmed} {
+			out := AreaDownsample(uniformImage(12, 8, tt.in), 3, 2, stat)
+			want := pixelColor(uniformImage(1, 1, tt.in), 0, 0)
+			wr, wg, wb := want.RGB255()
+			for y := 0; y < 2; y++ {
+				for x := 0; x < 3; x++ {
+					c := nrgbaAt(out, x, y)
+					if c.A != 255 || absDiff(c.R, tt.want.R) > 1 || absDiff(c.G, tt.want.G) > 1 || absDiff(c.B, tt.want.B) > 1 {
+						t.Fatalf("%s/%s: клетка (%d, %d) = %v, ожидалось %v", tt.name, stat, x, y, c, tt.want)
+					}
+					if absDiff(c.R, wr) > 1 || absDiff(c.G, wg) > 1 || absDiff(c.B, wb) > 1 {
+						t.Fatalf("%s/%s: клетка (%d, %d) = %v расходится с pixelColor (%d, %d, %d)", tt.name, stat, x, y, c, wr, wg, wb)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package image
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"diamond-mosaic/internal/db"
+
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// InventoryMode — как подбор цветов учитывает складские остатки (см. db.PaletteColor.Available).
+type InventoryMode string
+
+const (
+	InventoryIgnore InventoryMode = "ignore" // остатки не учитываются
+	InventoryAvoid  InventoryMode = "avoid"  // цвета, которых нет на складе, не используются
+	InventoryCap    InventoryMode = "cap"    // вдобавок каждого цвета уходит не больше остатка
+)
+
+// ParseInventoryMode разбирает режим учёта остатков; пустая строка — InventoryIgnore.
+func ParseInventoryMode(s string) (InventoryMode, error) {
+	switch m := InventoryMode(s); m {
+	case "":
+		return InventoryIgnore, nil
+	case InventoryIgnore, InventoryAvoid, InventoryCap:
+		return m, nil
+	default:
+		return "", fmt.Errorf("неизвестный режим учёта остатков %q (ignore, avoid, cap)", s)
+	}
+}
+
+// SubstitutionReason — почему цвет пришлось заменить.
+type SubstitutionReason string
+
+const (
+	ReasonOutOfStock SubstitutionReason = "out_of_stock" // цвета нет на складе
+	ReasonStockLimit SubstitutionReason = "stock_limit"  // остатка не хватило на все клетки
+)
+
+// Substitution — замена цвета из-за складских остатков: Count клеток, которым подходил
+// цвет From, выложены цветом To.
+type Substitution struct {
+	From   db.PaletteColor
+	To     db.PaletteColor
+	Count  int
+	Reason SubstitutionReason
+}
+
+// AvoidOutOfStock возвращает сетку, в которой цвета, которых нет на складе, заменены
+// ближайшими к исходнику (source) цветами в наличии. Сетка wanted не меняется;
+// если заменять нечего, она же и возвращается.
+func AvoidOutOfStock(wanted [][]db.PaletteColor, source [][]colorful.Color, palette []db.PaletteColor) [][]db.PaletteColor {
+	available := make([]db.PaletteColor, 0, len(palette))
+	for _, pc := range palette {
+		if pc.Available() != 0 {
+			available = append(available, pc)
+		}
+	}
+	if len(available) == len(palette) {
+		return wanted
+	}
+	if len(available) == 0 {
+		log.Printf("[AvoidOutOfStock] на складе нет ни одного цвета палитры, остатки не учитываются")
+		return wanted
+	}
+
+	matched := make([][]db.PaletteColor, len(wanted))
+	for y, row := range wanted {
+		matched[y] = make([]db.PaletteColor, len(row))
+		for x, pc := range row {
+			if pc.DMCCode != "BLANK" && pc.Available() == 0 {
+				pc = findNearestColor(source[y][x], available)
+			}
+			matched[y][x] = pc
+		}
+	}
+	return matched
+}
+
+// StockSubstitutions сравнивает сетку ближайших цветов wanted с итоговой matched и сводит
+// замены из-за складских остатков: все клетки цветов, которых нет на складе, а при capped —
+// ещё и клетки сверх остатка (не больше, чем цвет превышал остаток в wanted). Клетки,
+// перекрашенные по другим причинам (редкие цвета, островки), в отчёт не попадают.
+func StockSubstitutions(wanted, matched [][]db.PaletteColor, capped bool) []Substitution {
+	// 1. Сколько клеток каждого цвета не помещалось в остаток
+	excess := map[string]int{}
+	if capped {
+		counts := map[string]int{}
+		colors := map[string]db.PaletteColor{}
+		for _, row := range wanted {
+			for _, pc := range row {
+				counts[pc.DMCCode]++
+				colors[pc.DMCCode] = pc
+			}
+		}
+		for code, pc := range colors {
+			if avail := pc.Available(); avail > 0 && counts[code] > avail {
+				excess[code] = counts[code] - avail
+			}
+		}
+	}
+
+	// 2. Сводим изменившиеся клетки
+	subs := map[[2]string]*Substitution{}
+	for y, row := range wanted {
+		for x, want := range row {
+			got := matched[y][x]
+			switch {
+			case want.DMCCode == "BLANK" || got.DMCCode == want.DMCCode:
+			case want.Available() == 0:
+				addSubstitution(subs, want, got, ReasonOutOfStock)
+			case excess[want.DMCCode] > 0:
+				excess[want.DMCCode]--
+				addSubstitution(subs, want, got, ReasonStockLimit)
+			}
+		}
+	}
+	return sortedSubstitutions(subs)
+}
+
+// cloneGrid возвращает копию сетки цветов.
+func cloneGrid(grid [][]db.PaletteColor) [][]db.PaletteColor {
+	out := make([][]db.PaletteColor, len(grid))
+	for y, row := range grid {
+		out[y] = append([]db.PaletteColor(nil), row...)
+	}
+	return out
+}
+
+// CapToStock ограничивает расход каждого цвета его остатком на складе. Из клеток цвета,
+// которого не хватает, остаются ближайшие к исходнику (source), остальные получают следующий
+// по близости цвет, остатка которого ещё хватает. Если на складе не хватает алмазов вообще,
+// лишние клетки сохраняют исходный цвет. Сетка matched меняется на месте.
+func CapToStock(matched [][]db.PaletteColor, source [][]colorful.Color, palette []db.PaletteColor) []Substitution {
+	// 1. Считаем расход и находим цвета, которых не хватает
+	counts := map[string]int{}
+	for _, row := range matched {
+		for _, pc := range row {
+			counts[pc.DMCCode]++
+		}
+	}
+	type cell struct {
+		x, y int
+		dist float64
+	}
+	over := map[string][]cell{}
+	for _, pc := range palette {
+		if avail := pc.Available(); avail > 0 && counts[pc.DMCCode] > avail {
+			over[pc.DMCCode] = nil
+		}
+	}
+	if len(over) == 0 {
+		return nil
+	}
+
+	// 2. Оставляем каждому такому цвету самые подходящие клетки, остальные освобождаем
+	for y, row := range matched {
+		for x, pc := range row {
+			if _, ok := over[pc.DMCCode]; ok {
+				over[pc.DMCCode] = append(over[pc.DMCCode], cell{x: x, y: y, dist: labDistance(source[y][x], pc.Color)})
+			}
+		}
+	}
+	codes := make([]string, 0, len(over))
+	for code := range over {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	var freed []cell
+	for _, code := range codes {
+		cells := over[code]
+		sort.SliceStable(cells, func(i, j int) bool { return cells[i].dist < cells[j].dist })
+		limit := matched[cells[0].y][cells[0].x].Available()
+		freed = append(freed, cells[limit:]...)
+		counts[code] = limit
+	}
+
+	// 3. Раздаём освободившиеся клетки ближайшим цветам, остатка которых ещё хватает
+	hasRoom := func(pc db.PaletteColor) bool {
+		avail := pc.Available()
+		return avail < 0 || counts[pc.DMCCode] < avail
+	}
+	candidates := make([]db.PaletteColor, 0, len(palette))
+	for _, pc := range palette {
+		if pc.Available() != 0 && hasRoom(pc) {
+			candidates = append(candidates, pc)
+		}
+	}
+	subs := map[[2]string]*Substitution{}
+	short := 0
+	for _, c := range freed {
+		from := matched[c.y][c.x]
+		if len(candidates) == 0 {
+			short++
+			continue
+		}
+		to := findNearestColor(source[c.y][c.x], candidates)
+		matched[c.y][c.x] = to
+		counts[to.DMCCode]++
+		addSubstitution(subs, from, to, ReasonStockLimit)
+		if !hasRoom(to) {
+			for i, pc := range candidates {
+				if pc.DMCCode == to.DMCCode {
+					candidates = append(candidates[:i], candidates[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+	if short > 0 {
+		log.Printf("[CapToStock] алмазов на складе не хватает: %d клеток оставлены без замены", short)
+	}
+	return sortedSubstitutions(subs)
+}
+
+// addSubstitution учитывает одну заменённую клетку.
+func addSubstitution(subs map[[2]string]*Substitution, from, to db.PaletteColor, reason SubstitutionReason) {
+	key := [2]string{from.DMCCode, to.DMCCode}
+	s, ok := subs[key]
+	if !ok {
+		s = &Substitution{From: from, To: to, Reason: reason}
+		subs[key] = s
+	}
+	s.Count++
+}
+
+// sortedSubstitutions возвращает замены по убыванию числа клеток, при равенстве — по кодам.
+func sortedSubstitutions(subs map[[2]string]*Substitution) []Substitution {
+	out := make([]Substitution, 0, len(subs))
+	for _, s := range subs {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		if out[i].From.DMCCode != out[j].From.DMCCode {
+			return out[i].From.DMCCode < out[j].From.DMCCode
+		}
+		return out[i].To.DMCCode < out[j].To.DMCCode
+	})
+	return out
+}
+
+// labDistance — квадрат евклидова расстояния между цветами в Lab (как в findNearestColor).
+func labDistance(c1, c2 colorful.Color) float64 {
+	l1, a1, b1 := c1.Lab()
+	l2, a2, b2 := c2.Lab()
+	return euclideanDistanceLab([3]float64{l1, a1, b1}, [3]float64{l2, a2, b2})
+}
@@ -0,0 +1,165 @@
+package image
+
+import (
+	"reflect"
+	"testing"
+
+	"diamond-mosaic/internal/db"
+
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// stockColor создаёт цвет палитры с серым уровнем v и остатком stock (0 — без ограничения,
+// -1 — нет на складе).
+func stockColor(code string, v float64, stock int) db.PaletteColor {
+	pc := testColor(code, v)
+	if stock < 0 {
+		pc.OutOfStock = true
+	} else {
+		pc.Stock = stock
+	}
+	return pc
+}
+
+// codes возвращает коды цветов строки сетки.
+func codes(row []db.PaletteColor) []string {
+	out := make([]string, len(row))
+	for i, pc := range row {
+		out[i] = pc.DMCCode
+	}
+	return out
+}
+
+// grays возвращает строку цветов исходника с серыми уровнями values.
+func grays(values ...float64) []colorful.Color {
+	out := make([]colorful.Color, len(values))
+	for i, v := range values {
+		out[i] = colorful.Color{R: v, G: v, B: v}
+	}
+	return out
+}
+
+func TestParseInventoryMode(t *testing.T) {
+	for in, want := range map[string]InventoryMode{"": InventoryIgnore, "ignore": InventoryIgnore, "avoid": InventoryAvoid, "cap": InventoryCap} {
+		if got, err := ParseInventoryMode(in); err != nil || got != want {
+			t.Errorf("ParseInventoryMode(%q) = %q, %v; ожидалось %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseInventoryMode("always"); err == nil {
+		t.Error("ожидалась ошибка для неизвестного режима")
+	}
+}
+
+func TestAvoidOutOfStock(t *testing.T) {
+	a, b, gone := stockColor("A", 0.2, 0), stockColor("B", 0.8, 0), stockColor("C", 0.3, -1)
+	wanted := [][]db.PaletteColor{{a, gone, b, BlankColor()}}
+	source := [][]colorful.Color{grays(0.2, 0.3, 0.8, 1)}
+
+	matched := AvoidOutOfStock(wanted, source, []db.PaletteColor{a, b, gone})
+	if got, want := codes(matched[0]), []string{"A", "A", "B", "BLANK"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("сетка %v, ожидалось %v", got, want)
+	}
+	if wanted[0][1].DMCCode != "C" {
+		t.Error("исходная сетка изменилась")
+	}
+
+	// Всё в наличии — возвращается та же сетка
+	all := AvoidOutOfStock(wanted, source, []db.PaletteColor{a, b})
+	if &all[0][0] != &wanted[0][0] {
+		t.Error("без замен ожидалась та же сетка")
+	}
+}
+
+func TestCapToStock(t *testing.T) {
+	a := stockColor("A", 0.5, 2)  // остатка хватает на две клетки
+	b := stockColor("B", 0.6, 0)  // без ограничения
+	c := stockColor("C", 0.45, 1) // ближе к лишним клеткам, но остаток — одна клетка
+	d := stockColor("D", 0.1, -1) // нет на складе, кандидатом быть не может
+	palette := []db.PaletteColor{a, b, c, d}
+
+	matched := [][]db.PaletteColor{{a, a, a, a, b}}
+	source := [][]colorful.Color{grays(0.5, 0.44, 0.51, 0.46, 0.6)}
+	subs := CapToStock(matched, source, palette)
+
+	// Остаются клетки, ближайшие к A (0,5 и 0,51). Освободившиеся раздаются в том же порядке:
+	// 0,46 получает C, и остаток C кончается, поэтому 0,44 уходит в B
+	if got, want := codes(matched[0]), []string{"A", "B", "A", "C", "B"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("сетка %v, ожидалось %v", got, want)
+	}
+	want := []Substitution{
+		{From: a, To: b, Count: 1, Reason: ReasonStockLimit},
+		{From: a, To: c, Count: 1, Reason: ReasonStockLimit},
+	}
+	if !reflect.DeepEqual(subs, want) {
+		t.Errorf("замены %+v, ожидалось %+v", subs, want)
+	}
+
+	// Остатка не хватает вообще: лишние клетки сохраняют цвет
+	only := stockColor("A", 0.5, 1)
+	matched = [][]db.PaletteColor{{only, only, only}}
+	if subs := CapToStock(matched, [][]colorful.Color{grays(0.5, 0.5, 0.5)}, []db.PaletteColor{only}); len(subs) != 0 {
+		t.Errorf("замены %+v без кандидатов", subs)
+	}
+	if got := codes(matched[0]); !reflect.DeepEqual(got, []string{"A", "A", "A"}) {
+		t.Errorf("сетка %v, ожидалось без изменений", got)
+	}
+
+	// Всего хватает — ничего не меняется
+	matched = [][]db.PaletteColor{{a, b, b}}
+	if subs := CapToStock(matched, [][]colorful.Color{grays(0.5, 0.6, 0.6)}, palette); subs != nil {
+		t.Errorf("замены %+v при достаточных остатках", subs)
+	}
+}
+
+func TestStockSubstitutions(t *testing.T) {
+	a := stockColor("A", 0.5, 2)
+	b := stockColor("B", 0.6, 0)
+	gone := stockColor("C", 0.3, -1)
+	blank := BlankColor()
+
+	tests := []struct {
+		name           string
+		wanted, result []db.PaletteColor
+		capped         bool
+		want           []Substitution
+	}{
+		{
+			name:   "нет на складе",
+			wanted: []db.PaletteColor{gone, gone, b, blank},
+			result: []db.PaletteColor{b, a, b, blank},
+			want: []Substitution{
+				{From: gone, To: a, Count: 1, Reason: ReasonOutOfStock},
+				{From: gone, To: b, Count: 1, Reason: ReasonOutOfStock},
+			},
+		},
+		{
+			name:   "перекраска островков и редких цветов не считается",
+			wanted: []db.PaletteColor{b, b, a},
+			result: []db.PaletteColor{a, b, b},
+			capped: true,
+		},
+		{
+			name:   "сверх остатка — не больше превышения",
+			wanted: []db.PaletteColor{a, a, a, a},
+			result: []db.PaletteColor{b, b, b, a}, // одна клетка перекрашена ещё и очисткой
+			capped: true,
+			want:   []Substitution{{From: a, To: b, Count: 2, Reason: ReasonStockLimit}},
+		},
+		{
+			name:   "без cap превышение остатка не считается",
+			wanted: []db.PaletteColor{a, a, a, a},
+			result: []db.PaletteColor{b, b, a, a},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := StockSubstitutions([][]db.PaletteColor{tt.wanted}, [][]db.PaletteColor{tt.result}, tt.capped)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("замены %+v, ожидалось %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package image
+
+import (
+	"image"
+	"log"
+	"sync"
+	"time"
+)
+
+// MedianFilter применяет медианный фильтр к изображению с ядром kernelSize. У краёв окно
+// обрезается границей изображения; при чётном числе значений берётся верхняя медиана.
+// Полупрозрачные пиксели берутся с цветом, умноженным на альфу (как в pixelColor),
+// результат непрозрачен.
+//
+// Окно сдвигается вдоль строки с гистограммами каналов (алгоритм Хуанга): на каждом шаге
+// из гистограмм убирается левый столбец окна и добавляется правый, а медиана сдвигается
+// от предыдущей. Время на пиксель растёт линейно с размером ядра, а не квадратично.
+func MedianFilter(img image.Image, kernelSize int) image.Image {
+	start := time.Now() // замер времени выполнения
+
+	src := toNRGBA(img)
+	h := src.Bounds().Dy()
+	filtered := image.NewNRGBA(img.Bounds())
+	offset := kernelSize / 2
+
+	var wg sync.WaitGroup
+	for y := 0; y < h; y++ {
+		wg.Add(1)
+		go func(y int) {
+			defer wg.Done()
+			medianRow(src, filtered, y, offset)
+		}(y)
+	}
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	log.Printf("[MedianFilter] Время выполнения: %s", elapsed)
+	return filtered
+}
+
+// medianRow фильтрует строку y изображения src в dst окном (2·offset+1)².
+func medianRow(src, dst *image.NRGBA, y, offset int) {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	y0, y1 := y-offset, y+offset
+	if y0 < 0 {
+		y0 = 0
+	}
+	if y1 > h-1 {
+		y1 = h - 1
+	}
+
+	// Гистограммы каналов окна; для каждого канала — текущая медиана m
+	// и число значений окна меньше неё (below)
+	var hist [3][256]int
+	var m, below [3]int
+	n := 0
+	column := func(x, delta int) {
+		off := src.PixOffset(bounds.Min.X+x, bounds.Min.Y+y0)
+		for yy := y0; yy <= y1; yy++ {
+			a := src.Pix[off+3]
+			for c := 0; c < 3; c++ {
+				v := int(premultiply(src.Pix[off+c], a))
+				hist[c][v] += delta
+				if v < m[c] {
+					below[c] += delta
+				}
+			}
+			off += src.Stride
+		}
+		n += delta * (y1 - y0 + 1)
+	}
+
+	for x := 0; x <= offset && x < w; x++ {
+		column(x, 1)
+	}
+	o := dst.PixOffset(dst.Rect.Min.X, dst.Rect.Min.Y+y)
+	for x := 0; x < w; x++ {
+		if x > 0 {
+			if left := x - offset - 1; left >= 0 {
+				column(left, -1)
+			}
+			if right := x + offset; right < w {
+				column(right, 1)
+			}
+		}
+
+		// Сдвигаем медиану каждого канала к значению с номером n/2 в порядке возрастания
+		k := n / 2
+		for c := 0; c < 3; c++ {
+			for below[c] > k {
+				m[c]--
+				below[c] -= hist[c][m[c]]
+			}
+			for below[c]+hist[c][m[c]] <= k {
+				below[c] += hist[c][m[c]]
+				m[c]++
+			}
+			dst.Pix[o+c] = uint8(m[c])
+		}
+		dst.Pix[o+3] = 255
+		o += 4
+	}
+}
@@ -0,0 +1,164 @@
+package image
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// randomImage создаёт изображение w×h со случайными компонентами из levels равномерных уровней;
+// при opaque альфа всегда 255, иначе тоже случайна.
+func randomImage(rng *rand.Rand, w, h, levels int, opaque bool) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		if i%4 == 3 && opaque {
+			img.Pix[i] = 255
+		} else {
+			img.Pix[i] = uint8(rng.Intn(levels) * 255 / (levels - 1))
+		}
+	}
+	return img
+}
+
+// referenceMedian — медианный фильтр «в лоб»: для каждого пикселя собирает значения окна,
+// обрезанного границей изображения, сортирует их и берёт верхнюю медиану. Цвет берётся
+// умноженным на альфу через color.Color.RGBA, как у старой реализации.
+func referenceMedian(img *image.NRGBA, kernelSize int) *image.NRGBA {
+	bounds := img.Bounds()
+	dst := image.NewNRGBA(bounds)
+	offset := kernelSize / 2
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			var values [3][]int
+			for ny := y - offset; ny <= y+offset; ny++ {
+				for nx := x - offset; nx <= x+offset; nx++ {
+					if !(image.Point{X: nx, Y: ny}).In(bounds) {
+						continue
+					}
+					r, g, b, _ := img.At(nx, ny).RGBA()
+					values[0] = append(values[0], int(r>>8))
+					values[1] = append(values[1], int(g>>8))
+					values[2] = append(values[2], int(b>>8))
+				}
+			}
+			var m [3]uint8
+			for c := range values {
+				sort.Ints(values[c])
+				m[c] = uint8(values[c][len(values[c])/2])
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{R: m[0], G: m[1], B: m[2], A: 255})
+		}
+	}
+	return dst
+}
+
+func TestMedianFilter(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	big := randomImage(rng, 60, 50, 256, true)
+	translucent := randomImage(rng, 60, 50, 256, false)
+	images := []struct {
+		name string
+		img  *image.NRGBA
+	}{
+		{"1×1", randomImage(rng, 1, 1, 256, true)},
+		{"4×3", randomImage(rng, 4, 3, 256, true)},
+		{"2×9, мало уровней", randomImage(rng, 2, 9, 3, true)},
+		{"37×29", randomImage(rng, 37, 29, 256, true)},
+		{"37×29, мало уровней", randomImage(rng, 37, 29, 4, true)},
+		{"часть изображения", big.SubImage(image.Rect(5, 7, 45, 40)).(*image.NRGBA)},
+		{"узкая часть изображения", big.SubImage(image.Rect(20, 3, 23, 48)).(*image.NRGBA)},
+		{"4×3, полупрозрачное", randomImage(rng, 4, 3, 256, false)},
+		{"37×29, полупрозрачное", randomImage(rng, 37, 29, 256, false)},
+		{"37×29, полупрозрачное, мало уровней", randomImage(rng, 37, 29, 3, false)},
+		{"часть полупрозрачного изображения", translucent.SubImage(image.Rect(5, 7, 45, 40)).(*image.NRGBA)},
+	}
+	for _, k := range []int{3, 5, 15} {
+		for _, tt := range images {
+			t.Run(fmt.Sprintf("k=%d/%s", k, tt.name), func(t *testing.T) {
+				want := referenceMedian(tt.img, k)
+				got := MedianFilter(tt.img, k)
+				if got.Bounds() != want.Bounds() {
+					t.Fatalf("границы %v, ожидалось %v", got.Bounds(), want.Bounds())
+				}
+				b := want.Bounds()
+				for y := b.Min.Y; y < b.Max.Y; y++ {
+					for x := b.Min.X; x < b.Max.X; x++ {
+						if g, w := nrgbaAt(got, x, y), want.NRGBAAt(x, y); g != w {
+							t.Fatalf("пиксель (%d, %d) = %v, ожидалось %v", x, y, g, w)
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+// oldMedianFilter — прежняя реализация MedianFilter (сортировка окна для каждого пикселя),
+// оставлена для сравнения в бенчмарке.
+func oldMedianFilter(img image.Image, kernelSize int) image.Image {
+	var wg sync.WaitGroup
+	bounds := img.Bounds()
+	filtered := image.NewRGBA(bounds)
+	offset := kernelSize / 2
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		wg.Add(1)
+		go func(y int) {
+			defer wg.Done()
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				var rs, gs, bs []uint8
+				for ky := -offset; ky <= offset; ky++ {
+					for kx := -offset; kx <= offset; kx++ {
+						nx, ny := x+kx, y+ky
+						if nx < bounds.Min.X || nx >= bounds.Max.X || ny < bounds.Min.Y || ny >= bounds.Max.Y {
+							continue
+						}
+						r, g, b, _ := img.At(nx, ny).RGBA()
+						rs = append(rs, uint8(r>>8))
+						gs = append(gs, uint8(g>>8))
+						bs = append(bs, uint8(b>>8))
+					}
+				}
+				filtered.Set(x, y, color.RGBA{R: oldMedian(rs), G: oldMedian(gs), B: oldMedian(bs), A: 255})
+			}
+		}(y)
+	}
+	wg.Wait()
+	return filtered
+}
+
+// oldMedian — медиана прежней реализации (сортировка обменами).
+func oldMedian(data []uint8) uint8 {
+	n := len(data)
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			if data[j] < data[i] {
+				data[i], data[j] = data[j], data[i]
+			}
+		}
+	}
+	return data[n/2]
+}
+
+func BenchmarkMedianFilter(b *testing.B) {
+	img := randomImage(rand.New(rand.NewSource(1)), 256, 256, 256, true)
+	filters := []struct {
+		name string
+		fn   func(image.Image, int) image.Image
+	}{
+		{"new", MedianFilter},
+		{"old", oldMedianFilter},
+	}
+	for _, k := range []int{3, 7, 15} {
+		for _, f := range filters {
+			b.Run(fmt.Sprintf("k=%d/%s", k, f.name), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					f.fn(img, k)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package image
+
+import (
+	"image"
+
+	"github.com/disintegration/imaging"
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// Конвейер работает с буферами пикселей напрямую: At и Set на каждый пиксель
+// создают интерфейсные значения цвета и в разы медленнее.
+
+// toNRGBA возвращает изображение как *image.NRGBA, копируя его только при другом формате.
+func toNRGBA(img image.Image) *image.NRGBA {
+	if nrgba, ok := img.(*image.NRGBA); ok {
+		return nrgba
+	}
+	return imaging.Clone(img)
+}
+
+// pixelColor возвращает цвет пикселя (x, y) так же, как img.At(x, y).RGBA():
+// полупрозрачный пиксель умножается на альфу, то есть темнеет.
+func pixelColor(img *image.NRGBA, x, y int) colorful.Color {
+	i := img.PixOffset(x, y)
+	s := img.Pix[i : i+4 : i+4]
+	a := uint32(s[3])
+	return colorful.Color{
+		R: float64(uint32(s[0])*0x101*a/0xff) / 65535.0,
+		G: float64(uint32(s[1])*0x101*a/0xff) / 65535.0,
+		B: float64(uint32(s[2])*0x101*a/0xff) / 65535.0,
+	}
+}
+
+// fillRect закрашивает прямоугольник rect изображения img непрозрачным цветом (r, g, b).
+// Прямоугольник обрезается границами изображения.
+func fillRect(img *image.RGBA, rect image.Rectangle, r, g, b uint8) {
+	rect = rect.Intersect(img.Rect)
+	if rect.Empty() {
+		return
+	}
+	// Заполняем первую строку, остальные копируем из неё
+	first := img.Pix[img.PixOffset(rect.Min.X, rect.Min.Y):]
+	n := rect.Dx() * 4
+	for i := 0; i < n; i += 4 {
+		first[i], first[i+1], first[i+2], first[i+3] = r, g, b, 255
+	}
+	for y := rect.Min.Y + 1; y < rect.Max.Y; y++ {
+		copy(img.Pix[img.PixOffset(rect.Min.X, y):][:n], first[:n])
+	}
+}
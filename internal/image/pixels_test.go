@@ -0,0 +1,223 @@
+package image
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"diamond-mosaic/internal/db"
+
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// Прежние реализации через At и Set: новые, работающие с буферами пикселей,
+// должны давать тот же результат.
+
+// oldSourceColors — прежняя SourceColors.
+func oldSourceColors(src image.Image, indexGrid [][][2]int) [][]colorful.Color {
+	colors := make([][]colorful.Color, len(indexGrid))
+	for y, row := range indexGrid {
+		colors[y] = make([]colorful.Color, len(row))
+		for x, idx := range row {
+			if idx[0] < 0 || idx[1] < 0 {
+				colors[y][x] = colorful.Color{R: 1, G: 1, B: 1}
+				continue
+			}
+			r, g, b, _ := src.At(idx[0], idx[1]).RGBA()
+			colors[y][x] = colorful.Color{R: float64(r) / 65535.0, G: float64(g) / 65535.0, B: float64(b) / 65535.0}
+		}
+	}
+	return colors
+}
+
+// oldMatchToPalette — прежняя MatchToPalette.
+func oldMatchToPalette(src image.Image, palette []db.PaletteColor, indexGrid [][][2]int) [][]db.PaletteColor {
+	h, w := len(indexGrid), len(indexGrid[0])
+	matched := make([][]db.PaletteColor, h)
+	var wg sync.WaitGroup
+	for y := 0; y < h; y++ {
+		matched[y] = make([]db.PaletteColor, w)
+		wg.Add(1)
+		go func(y int) {
+			defer wg.Done()
+			for x := 0; x < w; x++ {
+				idx := indexGrid[y][x]
+				if idx[0] >= 0 && idx[1] >= 0 {
+					r, g, b, _ := src.At(idx[0], idx[1]).RGBA()
+					pix := colorful.Color{R: float64(r) / 65535.0, G: float64(g) / 65535.0, B: float64(b) / 65535.0}
+					matched[y][x] = findNearestColor(pix, palette)
+				} else {
+					matched[y][x] = BlankColor()
+				}
+			}
+		}(y)
+	}
+	wg.Wait()
+	return matched
+}
+
+// oldRenderMosaic — прежняя RenderMosaic (без подсчёта цветов).
+func oldRenderMosaic(matched [][]db.PaletteColor, cellSize int) *image.RGBA {
+	h, w := len(matched), len(matched[0])
+	mosaic := image.NewRGBA(image.Rect(0, 0, w*cellSize, h*cellSize))
+	var wg sync.WaitGroup
+	for y := 0; y < h; y++ {
+		wg.Add(1)
+		go func(y int) {
+			defer wg.Done()
+			for x := 0; x < w; x++ {
+				nr, ng, nb := matched[y][x].Color.RGB255()
+				rect := image.Rect(x*cellSize, y*cellSize, (x+1)*cellSize, (y+1)*cellSize)
+				draw.Draw(mosaic, rect, &image.Uniform{C: color.RGBA{R: nr, G: ng, B: nb, A: 255}}, image.Point{}, draw.Src)
+				c := color.RGBA{R: 90, G: 90, B: 90, A: 255}
+				for xx := rect.Min.X; xx < rect.Max.X; xx++ {
+					mosaic.Set(xx, rect.Min.Y, c)
+					mosaic.Set(xx, rect.Max.Y-1, c)
+				}
+				for yy := rect.Min.Y; yy < rect.Max.Y; yy++ {
+					mosaic.Set(rect.Min.X, yy, c)
+					mosaic.Set(rect.Max.X-1, yy, c)
+				}
+			}
+		}(y)
+	}
+	wg.Wait()
+	return mosaic
+}
+
+// randomPalette создаёт палитру из n случайных цветов.
+func randomPalette(rng *rand.Rand, n int) []db.PaletteColor {
+	p := make([]db.PaletteColor, n)
+	for i := range p {
+		p[i] = db.PaletteColor{DMCCode: fmt.Sprint(i), Color: colorful.Color{R: rng.Float64(), G: rng.Float64(), B: rng.Float64()}}
+	}
+	return p
+}
+
+func TestPixelColor(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, opaque := range []bool{true, false} {
+		img := randomImage(rng, 64, 64, 256, opaque)
+		for y := 0; y < 64; y++ {
+			for x := 0; x < 64; x++ {
+				r, g, b, _ := img.At(x, y).RGBA()
+				want := colorful.Color{R: float64(r) / 65535.0, G: float64(g) / 65535.0, B: float64(b) / 65535.0}
+				if got := pixelColor(img, x, y); got != want {
+					t.Fatalf("пиксель (%d, %d) %v: %v, ожидалось %v", x, y, img.NRGBAAt(x, y), got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestFillRect(t *testing.T) {
+	rects := []image.Rectangle{
+		image.Rect(2, 3, 7, 9),
+		image.Rect(0, 0, 1, 1),
+		image.Rect(-3, 5, 4, 20), // выходит за границы
+		image.Rect(12, 12, 20, 20),
+		image.Rect(5, 5, 5, 8), // пустой
+	}
+	for _, rect := range rects {
+		want := image.NewRGBA(image.Rect(0, 0, 16, 16))
+		got := image.NewRGBA(want.Rect)
+		draw.Draw(want, rect, &image.Uniform{C: color.RGBA{R: 10, G: 20, B: 30, A: 255}}, image.Point{}, draw.Src)
+		fillRect(got, rect, 10, 20, 30)
+		if !bytes.Equal(got.Pix, want.Pix) {
+			t.Errorf("fillRect(%v) расходится с draw.Draw", rect)
+		}
+	}
+}
+
+// TestPixelPaths сравнивает SourceColors, MatchToPalette и RenderMosaic с прежними
+// реализациями на непрозрачных и полупрозрачных изображениях, в том числе с пустыми клетками.
+func TestPixelPaths(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	palette := randomPalette(rng, 30)
+	tests := []struct {
+		srcW, srcH, gridW, gridH int
+		opaque                   bool
+	}{
+		{40, 30, 50, 50, true},
+		{37, 61, 40, 40, false},
+		{60, 60, 60, 60, false},
+		{1, 1, 3, 5, true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d×%d→%d×%d/opaque=%v", tt.srcW, tt.srcH, tt.gridW, tt.gridH, tt.opaque), func(t *testing.T) {
+			fitW, fitH, grid := MakeFitIndexGrid(tt.srcW, tt.srcH, tt.gridW, tt.gridH)
+			src := randomImage(rng, fitW, fitH, 256, tt.opaque)
+
+			wantColors, gotColors := oldSourceColors(src, grid), SourceColors(src, grid)
+			for y := range wantColors {
+				for x := range wantColors[y] {
+					if gotColors[y][x] != wantColors[y][x] {
+						t.Fatalf("SourceColors: клетка (%d, %d) = %v, ожидалось %v", x, y, gotColors[y][x], wantColors[y][x])
+					}
+				}
+			}
+
+			want, got := oldMatchToPalette(src, palette, grid), MatchToPalette(src, palette, grid)
+			for y := range want {
+				for x := range want[y] {
+					if got[y][x].DMCCode != want[y][x].DMCCode {
+						t.Fatalf("MatchToPalette: клетка (%d, %d) = %s, ожидалось %s", x, y, got[y][x].DMCCode, want[y][x].DMCCode)
+					}
+				}
+			}
+
+			for _, cellSize := range []int{1, 2, 10} {
+				wantImg := oldRenderMosaic(want, cellSize)
+				gotImg, _ := RenderMosaic(want, cellSize)
+				if gotImg.Rect != wantImg.Rect || !bytes.Equal(gotImg.Pix, wantImg.Pix) {
+					t.Fatalf("RenderMosaic: изображения при клетке %d различаются", cellSize)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMatchToPalette(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	src := randomImage(rng, 2000, 2000, 256, true)
+	_, _, grid := MakeFitIndexGrid(2000, 2000, 2000, 2000)
+	palette := randomPalette(rng, 4)
+	b.Run("new", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			MatchToPalette(src, palette, grid)
+		}
+	})
+	b.Run("old", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			oldMatchToPalette(src, palette, grid)
+		}
+	})
+}
+
+func BenchmarkRenderMosaic(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	palette := randomPalette(rng, 30)
+	matched := make([][]db.PaletteColor, 2000)
+	for y := range matched {
+		matched[y] = make([]db.PaletteColor, 2000)
+		for x := range matched[y] {
+			matched[y][x] = palette[rng.Intn(len(palette))]
+		}
+	}
+	// Клетка в 1 пиксель: при клетке 10 изображение заняло бы 1,6 ГБ
+	b.Run("new", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			RenderMosaic(matched, 1)
+		}
+	})
+	b.Run("old", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			oldRenderMosaic(matched, 1)
+		}
+	})
+}
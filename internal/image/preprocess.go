@@ -0,0 +1,124 @@
+package image
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/disintegration/imaging"
+)
+
+// ResampleFilter — фильтр масштабирования исходника до размеров сетки.
+type ResampleFilter string
+
+const (
+	ResampleArea       ResampleFilter = "area"       // цвет клетки по всем её пикселям (см. CellStat, AreaDownsample)
+	ResampleLanczos    ResampleFilter = "lanczos"    // Lanczos: резче, но возможны ореолы на контрастных границах
+	ResampleCatmullRom ResampleFilter = "catmullrom" // бикубическая интерполяция Catmull-Rom
+)
+
+// ParseResampleFilter разбирает фильтр масштабирования; пустая строка — ResampleArea.
+func ParseResampleFilter(s string) (ResampleFilter, error) {
+	switch f := ResampleFilter(s); f {
+	case "":
+		return ResampleArea, nil
+	case ResampleArea, ResampleLanczos, ResampleCatmullRom:
+		return f, nil
+	default:
+		return "", fmt.Errorf("неизвестный фильтр масштабирования %q (area, lanczos, catmullrom)", s)
+	}
+}
+
+// Ограничения параметров предобработки.
+const (
+	maxKernelSize = 15 // наибольшее ядро медианного фильтра
+	maxSharpen    = 5  // наибольшая сигма повышения резкости
+
+	// denoiseScale — во сколько раз (по стороне) рабочее изображение для шумоподавления
+	// больше сетки. Более мелкие детали всё равно усредняются внутри клетки.
+	denoiseScale = 4
+)
+
+// Preprocess — цепочка предобработки изображения перед подбором цветов.
+// Этапы выполняются по порядку: шумоподавление на исходнике, масштабирование до сетки,
+// повышение резкости и медианный фильтр на сетке. Нулевое значение этапа его отключает.
+type Preprocess struct {
+	Denoise  int            `json:"denoise"`   // ядро медианного фильтра на исходнике: нечётное от 3 до 15
+	Resample ResampleFilter `json:"resample"`  // фильтр масштабирования, пусто — area
+	CellStat CellStat       `json:"cell_stat"` // цвет клетки при area: среднее, медиана или усечённое среднее
+	Sharpen  float64        `json:"sharpen"`   // сигма повышения резкости после масштабирования
+	Median   int            `json:"median"`    // ядро медианного фильтра на сетке — сглаживает уже алмазы
+}
+
+// DefaultPreprocess возвращает цепочку по умолчанию: «соль и перец» убираются на исходнике,
+// затем каждая клетка получает средний в линейном свете цвет своих пикселей; сетка больше не размывается.
+func DefaultPreprocess() Preprocess {
+	return Preprocess{
+		Denoise:  3,
+		Resample: ResampleArea,
+		CellStat: CellMean,
+	}
+}
+
+// Validate проверяет параметры цепочки.
+func (p Preprocess) Validate() error {
+	if _, err := ParseResampleFilter(string(p.Resample)); err != nil {
+		return err
+	}
+	if _, err := ParseCellStat(string(p.CellStat)); err != nil {
+		return err
+	}
+	if err := validKernel(p.Denoise); err != nil {
+		return fmt.Errorf("denoise: %v", err)
+	}
+	if err := validKernel(p.Median); err != nil {
+		return fmt.Errorf("median: %v", err)
+	}
+	if p.Sharpen < 0 || p.Sharpen > maxSharpen {
+		return fmt.Errorf("sharpen должен быть от 0 до %d", maxSharpen)
+	}
+	return nil
+}
+
+// validKernel проверяет размер ядра медианного фильтра: 0 (выключен) или нечётное от 3 до maxKernelSize.
+func validKernel(size int) error {
+	if size == 0 || size%2 == 1 && size >= 3 && size <= maxKernelSize {
+		return nil
+	}
+	return fmt.Errorf("размер ядра должен быть 0 или нечётным от 3 до %d", maxKernelSize)
+}
+
+// Apply выполняет цепочку над исходником src и возвращает изображение размером fitW×fitH —
+// по пикселю на клетку вписанной области.
+func (p Preprocess) Apply(src image.Image, fitW, fitH int) image.Image {
+	img := src
+
+	// 1. Шумоподавление на исходнике: одиночные выбросы убираются до того, как попадут в клетки.
+	// Большой исходник сначала уменьшается усреднением до denoiseScale пикселей на клетку
+	if p.Denoise > 1 {
+		if img.Bounds().Dx() > fitW*denoiseScale || img.Bounds().Dy() > fitH*denoiseScale {
+			img = AreaDownsample(img, fitW*denoiseScale, fitH*denoiseScale, CellMean)
+		}
+		img = MedianFilter(img, p.Denoise)
+	}
+
+	// 2. Масштабирование до размеров сетки
+	switch p.Resample {
+	case ResampleLanczos:
+		img = imaging.Resize(img, fitW, fitH, imaging.Lanczos)
+	case ResampleCatmullRom:
+		img = imaging.Resize(img, fitW, fitH, imaging.CatmullRom)
+	default:
+		img = AreaDownsample(img, fitW, fitH, p.CellStat)
+	}
+
+	// 3. Повышение резкости на сетке
+	if p.Sharpen > 0 {
+		img = imaging.Sharpen(img, p.Sharpen)
+	}
+
+	// 4. Медианный фильтр на сетке
+	if p.Median > 1 {
+		img = MedianFilter(img, p.Median)
+	}
+	return img
+}
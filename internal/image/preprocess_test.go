@@ -0,0 +1,192 @@
+package image
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// uniformImage создаёт изображение w×h одного цвета c.
+func uniformImage(w, h int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return img
+}
+
+// nrgbaAt возвращает пиксель (x, y) изображения как color.NRGBA.
+func nrgbaAt(img image.Image, x, y int) color.NRGBA {
+	return color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+}
+
+func TestParseResampleFilter(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    ResampleFilter
+		wantErr bool
+	}{
+		{"", ResampleArea, false},
+		{"area", ResampleArea, false},
+		{"lanczos", ResampleLanczos, false},
+		{"catmullrom", ResampleCatmullRom, false},
+		{"bicubic", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseResampleFilter(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseResampleFilter(%q) = %q, %v; ожидалось %q, ошибка %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestPreprocessValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		p       Preprocess
+		wantErr string // подстрока ошибки, пусто — ошибки нет
+	}{
+		{"по умолчанию", DefaultPreprocess(), ""},
+		{"нулевое значение", Preprocess{}, ""},
+		{"наибольшие ядра и резкость", Preprocess{Denoise: 15, Median: 15, Sharpen: 5}, ""},
+		{"чётное ядро denoise", Preprocess{Denoise: 4}, "denoise"},
+		{"чётное ядро median", Preprocess{Median: 2}, "median"},
+		{"ядро denoise больше 15", Preprocess{Denoise: 17}, "denoise"},
+		{"ядро median больше 15", Preprocess{Median: 21}, "median"},
+		{"ядро 1", Preprocess{Denoise: 1}, "denoise"},
+		{"отрицательная резкость", Preprocess{Sharpen: -1}, "sharpen"},
+		{"слишком сильная резкость", Preprocess{Sharpen: 5.5}, "sharpen"},
+		{"неизвестный фильтр", Preprocess{Resample: "bicubic"}, "фильтр масштабирования"},
+		{"неизвестный cell_stat", Preprocess{CellStat: "mode"}, "цвета клетки"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.p.Validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("неожиданная ошибка: %v", err)
+			case tt.wantErr != "" && err == nil:
+				t.Errorf("ожидалась ошибка с %q", tt.wantErr)
+			case tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr):
+				t.Errorf("ошибка %q не содержит %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestPreprocessApplyOrder проверяет, что шумоподавление идёт на исходнике до масштабирования:
+// одиночные белые точки на чёрном фоне (по одной в каждой будущей клетке) должны исчезнуть.
+// Если бы медиана считалась после масштабирования, все клетки стали бы одинаково серыми
+// и медиана их бы не исправила.
+func TestPreprocessApplyOrder(t *testing.T) {
+	const fit, cell = 10, 4
+	src := uniformImage(fit*cell, fit*cell, color.NRGBA{A: 255})
+	for y := 1; y < fit*cell; y += cell {
+		for x := 1; x < fit*cell; x += cell {
+			src.SetNRGBA(x, y, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+		}
+	}
+
+	tests := []struct {
+		name    string
+		p       Preprocess
+		wantMin uint8 // ожидаемый диапазон значения канала во всех клетках
+		wantMax uint8
+	}{
+		{"без шумоподавления", Preprocess{Resample: ResampleArea, CellStat: CellMean}, 60, 80},
+		{"шумоподавление 3×3", Preprocess{Denoise: 3, Resample: ResampleArea, CellStat: CellMean}, 0, 0},
+		{"медиана только на сетке", Preprocess{Resample: ResampleArea, CellStat: CellMean, Median: 3}, 60, 80},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := tt.p.Apply(src, fit, fit)
+			for y := 0; y < fit; y++ {
+				for x := 0; x < fit; x++ {
+					if c := nrgbaAt(out, x, y); c.R < tt.wantMin || c.R > tt.wantMax {
+						t.Fatalf("клетка (%d, %d) = %d, ожидалось от %d до %d", x, y, c.R, tt.wantMin, tt.wantMax)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestPreprocessApplyResample проверяет каждый фильтр масштабирования: размер результата
+// и сохранение однотонного цвета.
+func TestPreprocessApplyResample(t *testing.T) {
+	want := color.NRGBA{R: 100, G: 150, B: 200, A: 255}
+	src := uniformImage(97, 61, want)
+	for _, f := range []ResampleFilter{ResampleArea, ResampleLanczos, ResampleCatmullRom} {
+		for _, stat := range []CellStat{CellMean, CellMedian, CellTrimmed} {
+			t.Run(string(f)+"/"+string(stat), func(t *testing.T) {
+				out := Preprocess{Resample: f, CellStat: stat}.Apply(src, 20, 12)
+				if b := out.Bounds(); b.Dx() != 20 || b.Dy() != 12 {
+					t.Fatalf("размер %v, ожидалось 20×12", b.Size())
+				}
+				for y := 0; y < 12; y++ {
+					for x := 0; x < 20; x++ {
+						c := nrgbaAt(out, x, y)
+						if absDiff(c.R, want.R) > 1 || absDiff(c.G, want.G) > 1 || absDiff(c.B, want.B) > 1 {
+							t.Fatalf("клетка (%d, %d) = %v, ожидалось %v", x, y, c, want)
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestPreprocessApplySharpen проверяет, что резкость усиливает перепад на границе
+// и не меняет однотонные области.
+func TestPreprocessApplySharpen(t *testing.T) {
+	const w, h = 20, 10
+	src := uniformImage(w, h, color.NRGBA{R: 80, G: 80, B: 80, A: 255})
+	for y := 0; y < h; y++ {
+		for x := w / 2; x < w; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{R: 170, G: 170, B: 170, A: 255})
+		}
+	}
+	plain := Preprocess{Resample: ResampleArea}.Apply(src, w, h)
+	sharp := Preprocess{Resample: ResampleArea, Sharpen: 1}.Apply(src, w, h)
+
+	dark, light := nrgbaAt(sharp, w/2-1, h/2), nrgbaAt(sharp, w/2, h/2)
+	if dark.R >= nrgbaAt(plain, w/2-1, h/2).R || light.R <= nrgbaAt(plain, w/2, h/2).R {
+		t.Errorf("перепад на границе не усилен: %d/%d без резкости, %d/%d с резкостью",
+			nrgbaAt(plain, w/2-1, h/2).R, nrgbaAt(plain, w/2, h/2).R, dark.R, light.R)
+	}
+	if c := nrgbaAt(sharp, 0, 0); c.R != 80 {
+		t.Errorf("однотонная область изменилась: %d, ожидалось 80", c.R)
+	}
+}
+
+// TestPreprocessApplyMedian проверяет медианный фильтр на сетке: выброс в одной клетке
+// убирается только при заданном Median.
+func TestPreprocessApplyMedian(t *testing.T) {
+	const w, h = 9, 9
+	src := uniformImage(w, h, color.NRGBA{R: 50, G: 60, B: 70, A: 255})
+	src.SetNRGBA(4, 4, color.NRGBA{R: 250, G: 10, B: 200, A: 255})
+
+	tests := []struct {
+		median int
+		want   color.NRGBA
+	}{
+		{0, color.NRGBA{R: 250, G: 10, B: 200, A: 255}},
+		{3, color.NRGBA{R: 50, G: 60, B: 70, A: 255}},
+		{5, color.NRGBA{R: 50, G: 60, B: 70, A: 255}},
+	}
+	for _, tt := range tests {
+		out := Preprocess{Resample: ResampleArea, Median: tt.median}.Apply(src, w, h)
+		if got := nrgbaAt(out, 4, 4); got != tt.want {
+			t.Errorf("median %d: клетка с выбросом = %v, ожидалось %v", tt.median, got, tt.want)
+		}
+	}
+}
+
+// absDiff возвращает модуль разности a и b.
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
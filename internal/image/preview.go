@@ -0,0 +1,44 @@
+package image
+
+import (
+	"image"
+
+	"diamond-mosaic/internal/db"
+)
+
+// RenderPreview рисует уменьшенную картинку мозаики без символов и рамок клеток:
+// каждая клетка — квадрат одного цвета. Размер клетки подбирается так, чтобы
+// большая сторона картинки не превышала maxSide (но не меньше 1 пикселя на клетку).
+func RenderPreview(matched [][]db.PaletteColor, maxSide int) *image.RGBA {
+	h := len(matched)
+	w := 0
+	if h > 0 {
+		w = len(matched[0])
+	}
+	side := w
+	if h > side {
+		side = h
+	}
+	cell := 1
+	if side > 0 && maxSide/side > 1 {
+		cell = maxSide / side
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, w*cell, h*cell))
+	for y, row := range matched {
+		for x, pc := range row {
+			r, g, b := pc.Color.RGB255()
+			for py := y * cell; py < (y+1)*cell; py++ {
+				off := img.PixOffset(x*cell, py)
+				for px := 0; px < cell; px++ {
+					img.Pix[off] = r
+					img.Pix[off+1] = g
+					img.Pix[off+2] = b
+					img.Pix[off+3] = 255
+					off += 4
+				}
+			}
+		}
+	}
+	return img
+}
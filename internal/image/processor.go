@@ -1,38 +1,65 @@
 package image
 
 import (
+	"crypto/sha256"
+	"diamond-mosaic/fonts"
+	"diamond-mosaic/internal/bom"
 	"diamond-mosaic/internal/db"
+	"encoding/hex"
 	"image"
-	"image/color"
-	"image/draw"
 	"io"
-	"io/ioutil"
 	"log"
+	"sort"
 	"sync"
 	"time"
 
-	// "golang.org/x/image/font"
-
 	"github.com/disintegration/imaging"
 	"github.com/golang/freetype"
 	"github.com/lucasb-eyer/go-colorful"
+	"golang.org/x/image/math/fixed"
 )
 
-// allSymbols — набор символов для легенды схемы.
-var allSymbols = []string{
-	"A", "B", "C", "D", "E", "F", "G", "H", "I", "J", "K", "L", "M", "N", "O", "P", "Q", "R", "S", "T", "U", "V", "W", "X", "Y", "Z",
-	"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "m", "n", "o", "p", "q", "r", "s", "t", "u", "v", "w", "x", "y", "z",
-	"0", "1", "2", "3", "4", "5", "6", "7", "8", "9",
-	"!", "@", "#", "$", "%", "^", "&", "*", "(", ")", "-", "_", "+", "=", "~", "`",
-	"[", "]", "{", "}", "<", ">", "?", "/", "\\", "|", ".", ",", ":", ";", "'", "\"",
-}
-
 // MosaicSizeInfo описывает физические и "штучные" размеры основы и вписанного изображения.
 type MosaicSizeInfo struct {
-	BaseWidthCM, BaseHeightCM int // Основа в см
-	BaseWidthPX, BaseHeightPX int // Основа в "шт"
-	ImgWidthCM, ImgHeightCM   int // Картинка в см (занятое пространство)
-	ImgWidthPX, ImgHeightPX   int // Картинка в "шт"
+	BaseWidthCM  int `json:"base_width_cm"`  // Основа в см
+	BaseHeightCM int `json:"base_height_cm"` //
+	BaseWidthPX  int `json:"base_width_px"`  // Основа в "шт"
+	BaseHeightPX int `json:"base_height_px"` //
+	ImgWidthCM   int `json:"img_width_cm"`   // Картинка в см (занятое пространство)
+	ImgHeightCM  int `json:"img_height_cm"`  //
+	ImgWidthPX   int `json:"img_width_px"`   // Картинка в "шт"
+	ImgHeightPX  int `json:"img_height_px"`  //
+}
+
+// CellSize — размер одной клетки на картинке-схеме в пикселях.
+const CellSize = 10
+
+// Result — результат генерации схемы.
+type Result struct {
+	Mosaic  *image.RGBA         // картинка-схема с символами
+	Matched [][]db.PaletteColor // сетка подобранных цветов, Matched[y][x]
+	Usages  []ColorUsage        // цвета схемы с количеством алмазов
+	Size    MosaicSizeInfo      // размеры основы и изображения
+	Params  Params              // исходные параметры генерации
+
+	// Source — цвет исходного изображения в каждой клетке после масштабирования и фильтрации,
+	// Source[y][x]. Заполняется только при генерации из изображения (для оценки качества).
+	Source [][]colorful.Color
+
+	// Substitutions — замены цветов из-за складских остатков (см. Options.Inventory).
+	Substitutions []Substitution
+
+	// Kit — комплектация и стоимость набора; считается перед выгрузкой по текущим ценам.
+	Kit *bom.BOM
+}
+
+// Params — исходные параметры, по которым построена схема.
+type Params struct {
+	WidthCM        int     `json:"width_cm"`
+	HeightCM       int     `json:"height_cm"`
+	Options        Options `json:"options"`
+	SourceSHA256   string  `json:"source_sha256,omitempty"`   // хеш загруженного файла
+	PaletteVersion string  `json:"palette_version,omitempty"` // см. db.PaletteVersion
 }
 
 // ColorUsage связывает цвет из палитры с количеством пикселей (алмазов).
@@ -41,19 +68,60 @@ type ColorUsage struct {
 	Count        int
 }
 
+// Options — общие параметры генерации схемы (задаются конфигурацией сервера).
+type Options struct {
+	DrillSizeMM  float64       `json:"drill_size_mm"`       // размер одного алмаза в мм
+	RareColorMin int           `json:"rare_color_min"`      // цвета, которых меньше, заменяются ближайшими частыми
+	Inventory    InventoryMode `json:"inventory,omitempty"` // учёт складских остатков, пусто — не учитываются
+	Preprocess   Preprocess    `json:"preprocess"`          // предобработка изображения перед подбором цветов
+	Adjust       Adjustments   `json:"adjust"`              // коррекция яркости, контраста и цвета перед подбором
+	Cleanup      Cleanup       `json:"cleanup"`             // удаление мелких островков после подбора
+}
+
+// DefaultOptions возвращает параметры генерации по умолчанию.
+func DefaultOptions() Options {
+	return Options{
+		DrillSizeMM:  2.5,
+		RareColorMin: 30,
+		Inventory:    InventoryIgnore,
+		Preprocess:   DefaultPreprocess(),
+		Cleanup:      DefaultCleanup(),
+	}
+}
+
 // Process декодирует входное изображение, превращает его в мозаичный рисунок
 // и собирает список уникальных DMC-цветов с их количеством использования.
-func Process(file io.Reader, palette []db.PaletteColor, widthCm int, heightCm int) (image.Image, []ColorUsage, MosaicSizeInfo, error) {
-	// 1. Декодируем изображение
-	src, err := imaging.Decode(file)
+func Process(file io.Reader, palette []db.PaletteColor, widthCm int, heightCm int, opts Options) (Result, error) {
+	// 1. Подбираем цвета палитры для каждой клетки
+	res, err := MatchImage(file, palette, widthCm, heightCm, opts)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// 2. Назначаем символы оставшимся цветам
+	AssignSymbolsToMatched(res.Matched, RenderableGlyphs())
+
+	// 3. Рисуем схему с символами и пересчитываем usages
+	res.Mosaic, res.Usages = RenderScheme(res.Matched)
+	return res, nil
+}
+
+// MatchImage выполняет часть конвейера Process до отрисовки: декодирование,
+// предобработку (opts.Preprocess), подбор цветов, удаление редких цветов и островков.
+// В результате заполнены все поля, кроме Mosaic; символы не назначены.
+func MatchImage(file io.Reader, palette []db.PaletteColor, widthCm int, heightCm int, opts Options) (Result, error) {
+	// 1. Декодируем изображение, попутно считая хеш исходного файла
+	hash := sha256.New()
+	src, err := imaging.Decode(io.TeeReader(file, hash))
 	if err != nil {
-		return nil, nil, MosaicSizeInfo{}, err
+		return Result{}, err
 	}
+	io.Copy(hash, file) // дочитываем хвост, который декодеру не понадобился
 
 	// 2. Переводим см в мм и рассчитываем размеры сетки пользователя
 	widthMm := float64(widthCm) * 10.0
 	heightMm := float64(heightCm) * 10.0
-	strazMm := 2.5
+	strazMm := opts.DrillSizeMM
 	userGridW := int(widthMm / strazMm)
 	userGridH := int(heightMm / strazMm)
 	srcW := src.Bounds().Dx()
@@ -62,48 +130,134 @@ func Process(file io.Reader, palette []db.PaletteColor, widthCm int, heightCm in
 	// 3. Вписываем изображение в сетку основы (по центру)
 	fitW, fitH, indexGrid := MakeFitIndexGrid(srcW, srcH, userGridW, userGridH)
 
-	// 3. Масштабирование
-	resized := imaging.Resize(src, fitW, fitH, imaging.CatmullRom)
-
-	// 4. Фильтрация
-	filtered := MedianFilter(resized, 3)
-
-	// 5. Подбираем ближайшие цвета для каждого пикселя
-	matched := MatchToPalette(filtered, palette, indexGrid)
+	// 4. Предобработка: шумоподавление, масштабирование до сетки, резкость, фильтрация;
+	// затем коррекция изображения — уже по клеткам, она поточечная. Дальше сетка читается
+	// прямо из буфера пикселей, поэтому приводится к NRGBA один раз
+	filtered := toNRGBA(opts.Adjust.Apply(opts.Preprocess.Apply(src, fitW, fitH)))
+
+	// 5. Подбираем ближайшие цвета для каждого пикселя; с учётом остатков цвета,
+	// которых нет на складе, заменяются ближайшими из имеющихся. Сетка wanted остаётся
+	// нетронутой: по ней в конце составляется отчёт о заменах
+	source := SourceColors(filtered, indexGrid)
+	wanted := MatchToPalette(filtered, palette, indexGrid)
+	matched := wanted
+	if opts.Inventory == InventoryAvoid || opts.Inventory == InventoryCap {
+		matched = cloneGrid(AvoidOutOfStock(wanted, source, palette))
+	}
 
-	// 6. Назначаем символы цветам
-	AssignSymbolsToMatched(matched, allSymbols)
+	// 6. В режиме cap ограничиваем расход каждого цвета остатком на складе — до удаления
+	// редких цветов и островков, чтобы они убрали и одиночные клетки, появившиеся при замене
+	if opts.Inventory == InventoryCap {
+		CapToStock(matched, source, palette)
+	}
 
-	// 7. Генерируем картинку, считаем использование цветов
-	const cellSize = 10
-	_, usages := RenderMosaic(matched, cellSize)
-	RemoveRareColors(matched, usages, 30) // удаляем редкие цвета
-	mosaic, usages := RenderMosaic(matched, cellSize) 	// пересчитываем usages и картинку
+	// 7. Считаем использование цветов и удаляем редкие, затем убираем мелкие островки
+	usages := CountUsages(matched)
+	RemoveRareColors(matched, usages, opts.RareColorMin)
+	CleanupIslands(matched, opts.Cleanup)
 
-	// 8. Конвертируем изображение в RGBA
-	rgbaImg, ok := mosaic.(*image.RGBA)
-	if !ok {
-		bounds := mosaic.Bounds()
-		tmp := image.NewRGBA(bounds)
-		draw.Draw(tmp, bounds, mosaic, bounds.Min, draw.Src)
-		rgbaImg = tmp
+	// 8. Перекраска редких цветов и островков могла снова превысить остаток, поэтому
+	// в режиме cap ограничение применяется ещё раз. Отчёт о заменах составляется один раз —
+	// по итоговой сетке
+	if opts.Inventory == InventoryCap {
+		CapToStock(matched, source, palette)
 	}
-
-	// 9. Наносим символы на изображение
-	err = DrawSymbolsOnImage(rgbaImg, matched, cellSize, "fonts/DejaVuSans.ttf")
-	if err != nil {
-		log.Printf("ошибка нанесения символов: %v", err)
+	var substitutions []Substitution
+	if opts.Inventory == InventoryAvoid || opts.Inventory == InventoryCap {
+		substitutions = StockSubstitutions(wanted, matched, opts.Inventory == InventoryCap)
 	}
 
-	// 10. Формируем структуру с информацией о размерах
+	// 9. Формируем структуру с информацией о размерах
 	sizeInfo := CalcMosaicSizeInfo(
 		widthCm, heightCm, // пользовательские размеры
 		userGridW, userGridH, // вся сетка основы
 		fitW, fitH, // вписанное изображение
-		2.5, // размер 1 алмаза в мм
+		strazMm, // размер 1 алмаза в мм
 	)
 
-	return mosaic, usages, sizeInfo, nil
+	params := Params{
+		WidthCM:        widthCm,
+		HeightCM:       heightCm,
+		Options:        opts,
+		SourceSHA256:   hex.EncodeToString(hash.Sum(nil)),
+		PaletteVersion: db.PaletteVersion(palette),
+	}
+	return Result{
+		Matched:       matched,
+		Usages:        CountUsages(matched),
+		Size:          sizeInfo,
+		Params:        params,
+		Source:        source,
+		Substitutions: substitutions,
+	}, nil
+}
+
+// ResultFromGrid строит результат по готовой сетке цветов (например, импортированной):
+// назначает символы, рисует схему и рассчитывает размеры по размеру алмаза.
+// Сетка целиком считается изображением, размеры основы совпадают с ним.
+func ResultFromGrid(matched [][]db.PaletteColor, opts Options) Result {
+	AssignSymbolsToMatched(matched, RenderableGlyphs())
+	mosaic, usages := RenderScheme(matched)
+
+	h := len(matched)
+	w := 0
+	if h > 0 {
+		w = len(matched[0])
+	}
+	widthCm := int(float64(w) * opts.DrillSizeMM / 10.0)
+	heightCm := int(float64(h) * opts.DrillSizeMM / 10.0)
+	return Result{
+		Mosaic:  mosaic,
+		Matched: matched,
+		Usages:  usages,
+		Size:    CalcMosaicSizeInfo(widthCm, heightCm, w, h, w, h, opts.DrillSizeMM),
+		Params:  Params{WidthCM: widthCm, HeightCM: heightCm, Options: opts},
+	}
+}
+
+// RenderScheme рисует картинку-схему с символами по готовой сетке цветов
+// и подсчитывает количество алмазов каждого цвета.
+func RenderScheme(matched [][]db.PaletteColor) (*image.RGBA, []ColorUsage) {
+	mosaic, usages := RenderMosaic(matched, CellSize)
+
+	// Наносим символы на изображение
+	if err := DrawSymbolsOnImage(mosaic, matched, CellSize); err != nil {
+		log.Printf("ошибка нанесения символов: %v", err)
+	}
+	return mosaic, usages
+}
+
+// CountUsages подсчитывает количество алмазов каждого цвета в сетке.
+// Результат отсортирован по убыванию количества, при равенстве — по коду DMC.
+func CountUsages(matched [][]db.PaletteColor) []ColorUsage {
+	usageMap := make(map[string]ColorUsage)
+	for y := range matched {
+		for x := range matched[y] {
+			pc := matched[y][x]
+			u := usageMap[pc.DMCCode]
+			if u.PaletteColor.DMCCode == "" {
+				u.PaletteColor = pc
+			}
+			u.Count++
+			usageMap[pc.DMCCode] = u
+		}
+	}
+	usages := make([]ColorUsage, 0, len(usageMap))
+	for _, u := range usageMap {
+		usages = append(usages, u)
+	}
+	SortUsages(usages)
+	return usages
+}
+
+// SortUsages сортирует цвета по убыванию количества, при равенстве — по коду DMC.
+func SortUsages(usages []ColorUsage) {
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].Count != usages[j].Count {
+			return usages[i].Count > usages[j].Count
+		}
+		return usages[i].PaletteColor.DMCCode < usages[j].PaletteColor.DMCCode
+	})
 }
 
 // CalcMosaicSizeInfo рассчитывает структуру с параметрами размеров мозаики и вписанного изображения.
@@ -131,7 +285,8 @@ func CalcMosaicSizeInfo(
 }
 
 // MatchToPalette подбирает к каждому пикселю (ячейке) ближайший цвет из палитры DMC.
-func MatchToPalette(src image.Image, palette []db.PaletteColor, indexGrid [][][2]int) [][]db.PaletteColor {
+// src — изображение после предобработки, по пикселю на клетку (см. Preprocess.Apply).
+func MatchToPalette(src *image.NRGBA, palette []db.PaletteColor, indexGrid [][][2]int) [][]db.PaletteColor {
 	start := time.Now() // замер времени выполнения
 
 	h := len(indexGrid)
@@ -147,20 +302,9 @@ func MatchToPalette(src image.Image, palette []db.PaletteColor, indexGrid [][][2
 			for x := 0; x < w; x++ {
 				idx := indexGrid[y][x]
 				if idx[0] >= 0 && idx[1] >= 0 {
-					r, g, b, _ := src.At(idx[0], idx[1]).RGBA()
-					pix := colorful.Color{
-						R: float64(r) / 65535.0,
-						G: float64(g) / 65535.0,
-						B: float64(b) / 65535.0,
-					}
-					matched[y][x] = findNearestColor(pix, palette)
+					matched[y][x] = findNearestColor(pixelColor(src, idx[0], idx[1]), palette)
 				} else {
-					matched[y][x] = db.PaletteColor{
-						DMCCode: "BLANK", // Только для пустых областей!
-						Name:    "Пусто",
-						Color:   colorful.Color{R: 1, G: 1, B: 1},
-						Symbol:  "",
-					}
+					matched[y][x] = BlankColor()
 				}
 			}
 		}(y)
@@ -191,6 +335,21 @@ func MakeFitIndexGrid(srcW, srcH, userGridW, userGridH int) (fitW, fitH int, pix
 	return fitW, fitH, pixelIndex
 }
 
+// BlankColor возвращает «цвет» пустой клетки вне вписанного изображения.
+func BlankColor() db.PaletteColor {
+	return db.PaletteColor{
+		DMCCode: "BLANK", // Только для пустых областей!
+		Name:    "Пусто",
+		Color:   colorful.Color{R: 1, G: 1, B: 1},
+		Symbol:  "",
+	}
+}
+
+// NearestColor возвращает ближайший к c цвет палитры (евклидова дистанция в Lab).
+func NearestColor(c colorful.Color, palette []db.PaletteColor) db.PaletteColor {
+	return findNearestColor(c, palette)
+}
+
 // findNearestColor ищет ближайший цвет в палитре по евклидовой дистанции в Lab.
 func findNearestColor(c colorful.Color, palette []db.PaletteColor) db.PaletteColor {
 	l1, a1, b1 := c.Lab()
@@ -218,43 +377,33 @@ func euclideanDistanceLab(lab1, lab2 [3]float64) float64 {
 }
 
 // RenderMosaic строит итоговое изображение и подсчитывает количество элементов каждого цвета.
-func RenderMosaic(matched [][]db.PaletteColor, cellSize int) (image.Image, []ColorUsage) {
+func RenderMosaic(matched [][]db.PaletteColor, cellSize int) (*image.RGBA, []ColorUsage) {
 	start := time.Now()
 
 	h := len(matched)
 	w := len(matched[0])
 	mosaic := image.NewRGBA(image.Rect(0, 0, w*cellSize, h*cellSize))
-	usageMap := make(map[string]ColorUsage)
 
 	var wg sync.WaitGroup
 
+	// Каждая строка рисуется в своей горутине: строки не пересекаются по пикселям
 	for y := 0; y < h; y++ {
 		wg.Add(1)
 		go func(y int) {
 			defer wg.Done()
 			for x := 0; x < w; x++ {
 				pc := matched[y][x]
-				u := usageMap[pc.DMCCode]
-				if u.PaletteColor.DMCCode == "" {
-					u.PaletteColor = pc
-				}
-				u.Count++
-				usageMap[pc.DMCCode] = u
-
 				nr, ng, nb := pc.Color.RGB255()
 				rect := image.Rect(x*cellSize, y*cellSize, (x+1)*cellSize, (y+1)*cellSize)
-				draw.Draw(mosaic, rect, &image.Uniform{C: color.RGBA{R: nr, G: ng, B: nb, A: 255}}, image.Point{}, draw.Src)
-				drawBorder(mosaic, rect, color.RGBA{R: 90, G: 90, B: 90, A: 255})
+				fillRect(mosaic, rect, nr, ng, nb)
+				drawBorder(mosaic, rect, 90, 90, 90)
 			}
 		}(y)
-
-		wg.Wait()
-	}
-	usages := make([]ColorUsage, 0, len(usageMap))
-	for _, u := range usageMap {
-		usages = append(usages, u)
 	}
+	wg.Wait()
+	usages := CountUsages(matched)
 	elapsed := time.Since(start)
+
 	log.Printf("[RenderMosaic] Время выполнения: %s", elapsed)
 
 	return mosaic, usages
@@ -313,98 +462,49 @@ func RemoveRareColors(matched [][]db.PaletteColor, usages []ColorUsage, minCount
 	}
 }
 
-// MedianFilter применяет медианный фильтр к изображению с ядром kernelSize.
-func MedianFilter(img image.Image, kernelSize int) image.Image {
-	start := time.Now() // замер времени выполнения
-	var wg sync.WaitGroup
-
-	bounds := img.Bounds()
-	filtered := image.NewRGBA(bounds)
-	offset := kernelSize / 2
-
-	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
-		wg.Add(1)
-		go func(y int) {
-			defer wg.Done()
-			for x := bounds.Min.X; x < bounds.Max.X; x++ {
-				var rs, gs, bs []uint8
-				for ky := -offset; ky <= offset; ky++ {
-					for kx := -offset; kx <= offset; kx++ {
-						nx := x + kx
-						ny := y + ky
-						if nx < bounds.Min.X || nx >= bounds.Max.X || ny < bounds.Min.Y || ny >= bounds.Max.Y {
-							continue
-						}
-						r, g, b, _ := img.At(nx, ny).RGBA()
-						rs = append(rs, uint8(r>>8))
-						gs = append(gs, uint8(g>>8))
-						bs = append(bs, uint8(b>>8))
-					}
-				}
-				medR := median(rs)
-				medG := median(gs)
-				medB := median(bs)
-				filtered.Set(x, y, color.RGBA{R: medR, G: medG, B: medB, A: 255})
-			}
-		}(y)
-	}
-
-	wg.Wait()
-	elapsed := time.Since(start)
-	log.Printf("[MedianFilter] Время выполнения: %s", elapsed)
-
-	return filtered
-}
-
-// median возвращает медиану из среза uint8.
-func median(data []uint8) uint8 {
-	n := len(data)
-	// Простейшая сортировка
-	for i := 0; i < n; i++ {
-		for j := i + 1; j < n; j++ {
-			if data[j] < data[i] {
-				data[i], data[j] = data[j], data[i]
-			}
-		}
-	}
-	return data[n/2]
-}
-
 // DrawSymbolsOnImage наносит символы на итоговое изображение-мозаику.
-func DrawSymbolsOnImage(img *image.RGBA, matched [][]db.PaletteColor, cellSize int, fontPath string) error {
-	fontBytes, err := ioutil.ReadFile(fontPath)
-	if err != nil {
-		return err
-	}
-	f, err := freetype.ParseFont(fontBytes)
+// Используется встроенный шрифт; каждый символ измеряется и центрируется в клетке.
+func DrawSymbolsOnImage(img *image.RGBA, matched [][]db.PaletteColor, cellSize int) error {
+	f, err := fonts.Default()
 	if err != nil {
 		return err
 	}
 	c := freetype.NewContext()
-	c.SetDPI(96)
+	c.SetDPI(72) // при 72 DPI кегль задаётся прямо в пикселях
 	c.SetFont(f)
-	c.SetFontSize(float64(cellSize) * 0.7) // размер символа чуть меньше клетки
 	c.SetClip(img.Bounds())
 	c.SetDst(img)
 
 	const brightnessThreshold = 0.5 // порог
 
+	// 1. Группируем клетки по символу, чтобы кегль менялся один раз на символ
+	cellsBySymbol := map[string][]image.Point{}
 	for y := 0; y < len(matched); y++ {
 		for x := 0; x < len(matched[0]); x++ {
 			pc := matched[y][x]
 			if pc.Symbol == "" || pc.DMCCode == "BLANK" {
 				continue // если не присвоено, пропускаем
 			}
+			cellsBySymbol[pc.Symbol] = append(cellsBySymbol[pc.Symbol], image.Point{X: x, Y: y})
+		}
+	}
 
+	// 2. Рисуем каждый символ по центру его клеток
+	for symbol, cells := range cellsBySymbol {
+		size, dx, dy, err := fonts.FitSymbol(symbol, float64(cellSize), SymbolFill)
+		if err != nil {
+			return err
+		}
+		c.SetFontSize(size)
+		for _, cell := range cells {
+			pc := matched[cell.Y][cell.X]
 			c.SetSrc(chooseSymbolColor(pc.Color, brightnessThreshold))
-			// координаты центра клетки
-			pt := freetype.Pt(
-				x*cellSize+cellSize/5,   // x
-				y*cellSize+cellSize*5/6, // y
-			)
-			_, err := c.DrawString(pc.Symbol, pt)
-			if err != nil {
-				log.Printf("ошибка рисования символа %q: %v", pc.Symbol, err)
+			pt := fixed.Point26_6{
+				X: fixed.Int26_6((float64(cell.X*cellSize) + dx) * 64),
+				Y: fixed.Int26_6((float64(cell.Y*cellSize) + dy) * 64),
+			}
+			if _, err := c.DrawString(symbol, pt); err != nil {
+				log.Printf("ошибка рисования символа %q: %v", symbol, err)
 			}
 		}
 	}
@@ -421,45 +521,16 @@ func chooseSymbolColor(col colorful.Color, threshold float64) image.Image {
 	return image.White
 }
 
-// AssignSymbolsToMatched назначает каждому цвету уникальный символ.
-func AssignSymbolsToMatched(matched [][]db.PaletteColor, allSymbols []string) {
-	symbolMap := map[string]string{} // DMC -> символ
-	symbolIdx := 0
-	for y := 0; y < len(matched); y++ {
-		for x := 0; x < len(matched[0]); x++ {
-			pc := &matched[y][x]
-			if pc.Symbol != "" {
-				continue
-			}
-			if sym, ok := symbolMap[pc.DMCCode]; ok {
-				pc.Symbol = sym
-			} else {
-				if symbolIdx < len(allSymbols) {
-					pc.Symbol = allSymbols[symbolIdx]
-					symbolMap[pc.DMCCode] = allSymbols[symbolIdx]
-					symbolIdx++
-				} else {
-					pc.Symbol = "?" // если символы закончились
-				}
-			}
-		}
-	}
-}
-
-// drawBorder рисует рамку вокруг одной клетки мозаики.
-func drawBorder(img *image.RGBA, rect image.Rectangle, c color.Color) {
+// drawBorder рисует рамку в пиксель толщиной вокруг одной клетки мозаики цветом (r, g, b).
+func drawBorder(img *image.RGBA, rect image.Rectangle, r, g, b uint8) {
 	minX, minY, maxX, maxY := rect.Min.X, rect.Min.Y, rect.Max.X, rect.Max.Y
 
 	// Горизонтальные линии
-	for x := minX; x < maxX; x++ {
-		img.Set(x, minY, c)   // верхняя
-		img.Set(x, maxY-1, c) // нижняя
-	}
+	fillRect(img, image.Rect(minX, minY, maxX, minY+1), r, g, b) // верхняя
+	fillRect(img, image.Rect(minX, maxY-1, maxX, maxY), r, g, b) // нижняя
 	// Вертикальные линии
-	for y := minY; y < maxY; y++ {
-		img.Set(minX, y, c)   // левая
-		img.Set(maxX-1, y, c) // правая
-	}
+	fillRect(img, image.Rect(minX, minY, minX+1, maxY), r, g, b) // левая
+	fillRect(img, image.Rect(maxX-1, minY, maxX, maxY), r, g, b) // правая
 }
 
 // ComputeFitArea вычисляет размеры вписанной области (в клетках) с сохранением пропорций
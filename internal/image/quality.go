@@ -0,0 +1,199 @@
+package image
+
+import (
+	"image"
+	"math"
+	"strconv"
+
+	"diamond-mosaic/fonts"
+
+	"github.com/golang/freetype"
+	"github.com/lucasb-eyer/go-colorful"
+	"golang.org/x/image/math/fixed"
+)
+
+// QualityStats — сводка ошибки цветопередачи схемы: ΔE (CIEDE2000) между цветом
+// исходного изображения в клетке и подобранным цветом палитры. Пустые клетки не учитываются.
+type QualityStats struct {
+	MeanDeltaE float64 `json:"mean_delta_e"`
+	MaxDeltaE  float64 `json:"max_delta_e"`
+	Cells      int     `json:"cells"` // число учтённых клеток
+}
+
+// heatmapMaxDeltaE — ΔE, начиная с которого клетка на тепловой карте красная.
+const heatmapMaxDeltaE = 20.0
+
+// heatmapStops — шкала тепловой карты: ΔE 0 — зелёный, ~2,3 (порог заметности) — жёлто-зелёный,
+// 10 — оранжевый, heatmapMaxDeltaE и больше — красный.
+var heatmapStops = []struct {
+	deltaE float64
+	color  colorful.Color
+}{
+	{0, colorful.Color{R: 0.10, G: 0.60, B: 0.31}},
+	{2.3, colorful.Color{R: 0.65, G: 0.85, B: 0.42}},
+	{5, colorful.Color{R: 1.00, G: 0.88, B: 0.55}},
+	{10, colorful.Color{R: 0.99, G: 0.55, B: 0.35}},
+	{heatmapMaxDeltaE, colorful.Color{R: 0.84, G: 0.19, B: 0.15}},
+}
+
+// comparisonPanelSide — большая сторона одной панели сравнения в пикселях.
+const comparisonPanelSide = 800
+
+// SourceColors возвращает цвет исходного изображения src для каждой клетки сетки indexGrid
+// (см. MakeFitIndexGrid). Для клеток вне изображения возвращается белый цвет.
+func SourceColors(src *image.NRGBA, indexGrid [][][2]int) [][]colorful.Color {
+	colors := make([][]colorful.Color, len(indexGrid))
+	for y, row := range indexGrid {
+		colors[y] = make([]colorful.Color, len(row))
+		for x, idx := range row {
+			if idx[0] < 0 || idx[1] < 0 {
+				colors[y][x] = colorful.Color{R: 1, G: 1, B: 1}
+				continue
+			}
+			colors[y][x] = pixelColor(src, idx[0], idx[1])
+		}
+	}
+	return colors
+}
+
+// DeltaEGrid считает ΔE (CIEDE2000) для каждой клетки схемы. Для пустых клеток — NaN.
+// ok == false, если у результата нет цветов исходника (например, схема импортирована).
+func DeltaEGrid(res Result) (grid [][]float64, ok bool) {
+	if len(res.Source) != len(res.Matched) || len(res.Source) == 0 {
+		return nil, false
+	}
+	grid = make([][]float64, len(res.Matched))
+	for y, row := range res.Matched {
+		grid[y] = make([]float64, len(row))
+		for x, pc := range row {
+			if pc.DMCCode == "BLANK" {
+				grid[y][x] = math.NaN()
+				continue
+			}
+			grid[y][x] = res.Source[y][x].DistanceCIEDE2000(pc.Color) * 100
+		}
+	}
+	return grid, true
+}
+
+// Quality считает среднее и максимальное ΔE по схеме.
+func Quality(res Result) (QualityStats, bool) {
+	grid, ok := DeltaEGrid(res)
+	if !ok {
+		return QualityStats{}, false
+	}
+	var stats QualityStats
+	sum := 0.0
+	for _, row := range grid {
+		for _, d := range row {
+			if math.IsNaN(d) {
+				continue
+			}
+			sum += d
+			stats.Cells++
+			if d > stats.MaxDeltaE {
+				stats.MaxDeltaE = d
+			}
+		}
+	}
+	if stats.Cells > 0 {
+		stats.MeanDeltaE = sum / float64(stats.Cells)
+	}
+	return stats, true
+}
+
+// heatmapColor возвращает цвет тепловой карты для значения ΔE.
+func heatmapColor(d float64) colorful.Color {
+	if math.IsNaN(d) {
+		return colorful.Color{R: 1, G: 1, B: 1}
+	}
+	for i := 1; i < len(heatmapStops); i++ {
+		lo, hi := heatmapStops[i-1], heatmapStops[i]
+		if d <= hi.deltaE {
+			t := (d - lo.deltaE) / (hi.deltaE - lo.deltaE)
+			return lo.color.BlendRgb(hi.color, t)
+		}
+	}
+	return heatmapStops[len(heatmapStops)-1].color
+}
+
+// RenderComparison рисует три панели рядом: исходное изображение в разрешении сетки,
+// мозаику без символов и тепловую карту ΔE, под панелями — подписи и сводка.
+// ok == false, если у результата нет цветов исходника.
+func RenderComparison(res Result) (*image.RGBA, QualityStats, bool) {
+	grid, ok := DeltaEGrid(res)
+	if !ok {
+		return nil, QualityStats{}, false
+	}
+	stats, _ := Quality(res)
+
+	// 1. Размер клетки: панель не больше comparisonPanelSide и клетка не больше CellSize
+	h := len(res.Matched)
+	w := len(res.Matched[0])
+	side := w
+	if h > side {
+		side = h
+	}
+	cell := comparisonPanelSide / side
+	if cell > CellSize {
+		cell = CellSize
+	}
+	if cell < 1 {
+		cell = 1
+	}
+	const gap = 10     // промежуток между панелями
+	const caption = 28 // высота строки подписей
+	panelW, panelH := w*cell, h*cell
+	img := image.NewRGBA(image.Rect(0, 0, 3*panelW+4*gap, panelH+2*gap+caption))
+	fillRect(img, img.Bounds(), 255, 255, 255)
+
+	// 2. Панели: исходник, мозаика, тепловая карта
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			fillCell(img, gap, gap, x, y, cell, res.Source[y][x])
+			fillCell(img, 2*gap+panelW, gap, x, y, cell, res.Matched[y][x].Color)
+			fillCell(img, 3*gap+2*panelW, gap, x, y, cell, heatmapColor(grid[y][x]))
+		}
+	}
+
+	// 3. Подписи
+	labels := []string{
+		"Исходное изображение",
+		"Мозаика",
+		formatDeltaECaption(stats),
+	}
+	f, err := fonts.Default()
+	if err == nil {
+		c := freetype.NewContext()
+		c.SetDPI(72)
+		c.SetFont(f)
+		c.SetFontSize(14)
+		c.SetClip(img.Bounds())
+		c.SetDst(img)
+		c.SetSrc(image.Black)
+		for i, label := range labels {
+			pt := fixed.Point26_6{
+				X: fixed.I(gap + i*(panelW+gap)),
+				Y: fixed.I(gap + panelH + caption - 8),
+			}
+			c.DrawString(label, pt)
+		}
+	}
+	return img, stats, true
+}
+
+// formatDeltaECaption формирует подпись тепловой карты со сводкой ΔE.
+func formatDeltaECaption(stats QualityStats) string {
+	return "ΔE: среднее " + formatDeltaE(stats.MeanDeltaE) + ", макс. " + formatDeltaE(stats.MaxDeltaE)
+}
+
+// formatDeltaE печатает ΔE с одним знаком после запятой.
+func formatDeltaE(d float64) string {
+	return strconv.FormatFloat(d, 'f', 1, 64)
+}
+
+// fillCell закрашивает клетку (x, y) панели с началом (x0, y0) цветом c.
+func fillCell(img *image.RGBA, x0, y0, x, y, cell int, c colorful.Color) {
+	r, g, b := c.Clamped().RGB255()
+	fillRect(img, image.Rect(x0+x*cell, y0+y*cell, x0+(x+1)*cell, y0+(y+1)*cell), r, g, b)
+}
@@ -0,0 +1,300 @@
+package image
+
+import (
+	"sort"
+	"sync"
+
+	"diamond-mosaic/fonts"
+	"diamond-mosaic/internal/db"
+)
+
+// GlyphFamily — группа символов с похожими очертаниями.
+// Соседним по цвету DMC назначаются символы из разных групп,
+// чтобы их нельзя было спутать на распечатанной схеме.
+type GlyphFamily int
+
+const (
+	FamilyFilledShape  GlyphFamily = iota // залитые фигуры: ● ■ ▲
+	FamilyOutlineShape                    // контурные фигуры: ○ □ △
+	FamilyHalfShape                       // фигуры с половинной заливкой: ◐ ◧
+	FamilyPatternShape                    // штриховки и составные фигуры: ▦ ⊕
+	FamilyArrow                           // стрелки
+	FamilyMark                            // математические и прочие знаки
+	FamilyUpper                           // заглавные латинские буквы
+	FamilyLower                           // строчные латинские буквы
+	FamilyDigit                           // цифры
+	FamilyForeign                         // греческие и кириллические буквы
+	FamilyCombined                        // двухсимвольные комбинации
+)
+
+// Glyph — символ легенды и группа, к которой он относится.
+type Glyph struct {
+	Symbol string
+	Family GlyphFamily
+}
+
+// symbolGlyphs — курированный набор символов для легенды схемы.
+// Из набора исключены пары, неразличимые в мелком размере:
+// l/I/1/|, O/0/o, ,/., '/", B/8, S/5, Z/2, Δ/Д, ○/◎/◉, а также строчные буквы,
+// повторяющие заглавные (c, s, v...).
+// Порядок важен: самые частые цвета получают самые простые символы.
+var symbolGlyphs = []Glyph{
+	{"●", FamilyFilledShape}, {"■", FamilyFilledShape}, {"▲", FamilyFilledShape}, {"◆", FamilyFilledShape},
+	{"★", FamilyFilledShape}, {"▼", FamilyFilledShape}, {"♥", FamilyFilledShape}, {"♠", FamilyFilledShape},
+	{"♣", FamilyFilledShape}, {"◀", FamilyFilledShape}, {"▶", FamilyFilledShape},
+
+	{"○", FamilyOutlineShape}, {"□", FamilyOutlineShape}, {"△", FamilyOutlineShape}, {"◇", FamilyOutlineShape},
+	{"☆", FamilyOutlineShape}, {"▽", FamilyOutlineShape}, {"♡", FamilyOutlineShape},
+
+	{"◐", FamilyHalfShape}, {"◑", FamilyHalfShape}, {"◒", FamilyHalfShape}, {"◓", FamilyHalfShape},
+	{"◧", FamilyHalfShape}, {"◨", FamilyHalfShape}, {"◩", FamilyHalfShape}, {"◪", FamilyHalfShape},
+
+	{"▦", FamilyPatternShape}, {"▤", FamilyPatternShape}, {"▥", FamilyPatternShape}, {"▧", FamilyPatternShape},
+	{"▨", FamilyPatternShape}, {"⊕", FamilyPatternShape}, {"⊗", FamilyPatternShape}, {"⊞", FamilyPatternShape},
+	{"⊠", FamilyPatternShape}, {"▣", FamilyPatternShape},
+
+	{"←", FamilyArrow}, {"→", FamilyArrow}, {"↑", FamilyArrow}, {"↓", FamilyArrow},
+	{"↔", FamilyArrow}, {"↕", FamilyArrow},
+
+	{"+", FamilyMark}, {"×", FamilyMark}, {"÷", FamilyMark}, {"=", FamilyMark},
+	{"#", FamilyMark}, {"%", FamilyMark}, {"&", FamilyMark}, {"@", FamilyMark},
+	{"✓", FamilyMark}, {"♪", FamilyMark}, {"☼", FamilyMark}, {"⌂", FamilyMark},
+
+	{"A", FamilyUpper}, {"B", FamilyUpper}, {"C", FamilyUpper}, {"D", FamilyUpper}, {"E", FamilyUpper},
+	{"F", FamilyUpper}, {"G", FamilyUpper}, {"H", FamilyUpper}, {"J", FamilyUpper}, {"K", FamilyUpper},
+	{"L", FamilyUpper}, {"M", FamilyUpper}, {"N", FamilyUpper}, {"P", FamilyUpper}, {"R", FamilyUpper},
+	{"S", FamilyUpper}, {"T", FamilyUpper}, {"U", FamilyUpper}, {"V", FamilyUpper}, {"W", FamilyUpper},
+	{"X", FamilyUpper}, {"Y", FamilyUpper}, {"Z", FamilyUpper},
+
+	{"a", FamilyLower}, {"b", FamilyLower}, {"d", FamilyLower}, {"e", FamilyLower}, {"f", FamilyLower},
+	{"h", FamilyLower}, {"k", FamilyLower}, {"m", FamilyLower}, {"n", FamilyLower}, {"r", FamilyLower},
+	{"t", FamilyLower}, {"y", FamilyLower},
+
+	{"3", FamilyDigit}, {"4", FamilyDigit}, {"7", FamilyDigit},
+
+	{"Σ", FamilyForeign}, {"Φ", FamilyForeign}, {"Ψ", FamilyForeign},
+	{"Ω", FamilyForeign}, {"λ", FamilyForeign}, {"π", FamilyForeign}, {"Ж", FamilyForeign},
+	{"Щ", FamilyForeign}, {"Ю", FamilyForeign}, {"Я", FamilyForeign}, {"Б", FamilyForeign},
+	{"Л", FamilyForeign},
+}
+
+// comboAlphabet — алфавит для двухсимвольных комбинаций, которые
+// выдаются, когда основной набор закончился.
+var comboAlphabet = []string{
+	"A", "B", "C", "D", "E", "F", "G", "H", "J", "K", "L", "M",
+	"N", "P", "R", "S", "T", "U", "V", "W", "X", "Y", "Z",
+}
+
+// SymbolFill — доля стороны клетки, которую занимает символ (в PNG и в PDF одинаково).
+const SymbolFill = 0.7
+
+var (
+	renderableOnce   sync.Once
+	renderableGlyphs []Glyph
+)
+
+// RenderableGlyphs возвращает символы набора, которые есть во встроенном шрифте.
+func RenderableGlyphs() []Glyph {
+	renderableOnce.Do(func() {
+		for _, g := range symbolGlyphs {
+			if fonts.HasGlyphs(g.Symbol) {
+				renderableGlyphs = append(renderableGlyphs, g)
+			}
+		}
+	})
+	return renderableGlyphs
+}
+
+// similarColorDist — расстояние в Lab, ближе которого цвета считаются похожими
+// и должны получать символы из разных групп.
+const similarColorDist = 0.15
+
+// comboSymbol возвращает n-й составной символ (AA, AB, ..., ZZ, AAA, ...).
+// Нумерация биективная, поэтому количество символов не ограничено.
+func comboSymbol(n int) string {
+	base := len(comboAlphabet)
+	n += base + 1 // начинаем сразу с двух символов
+	s := ""
+	for n > 0 {
+		n--
+		s = comboAlphabet[n%base] + s
+		n /= base
+	}
+	return s
+}
+
+// AllowedSymbol сообщает, можно ли закрепить символ за цветом: он должен быть из курированного
+// набора или составным из букв comboAlphabet. Другие символы (I, l, 1, 0, O, |...) легко спутать.
+func AllowedSymbol(symbol string) bool {
+	for _, g := range symbolGlyphs {
+		if g.Symbol == symbol {
+			return true
+		}
+	}
+	if len(symbol) < 2 {
+		return false
+	}
+	for _, r := range symbol {
+		if !containsString(comboAlphabet, string(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsString сообщает, есть ли s среди list.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// SymbolAssigner раздаёт символы цветам схемы.
+type SymbolAssigner struct {
+	glyphs    []Glyph
+	used      []bool
+	nextCombo int
+	assigned  []assignedColor
+	reserved  map[string]bool // символы, уже выданные через Reserve
+}
+
+// assignedColor — цвет, которому уже выдан символ.
+type assignedColor struct {
+	color  db.PaletteColor
+	family GlyphFamily
+}
+
+// NewSymbolAssigner создаёт раздатчик символов на основе набора glyphs.
+func NewSymbolAssigner(glyphs []Glyph) *SymbolAssigner {
+	return &SymbolAssigner{
+		glyphs:   glyphs,
+		used:     make([]bool, len(glyphs)),
+		reserved: map[string]bool{},
+	}
+}
+
+// Reserve закрепляет за цветом pc заранее выбранный символ (см. db.PaletteColor.PreferredSymbol).
+// Возвращает false, если символ уже занят или недопустим (см. AllowedSymbol) — тогда цвету
+// нужно выдать символ через Assign.
+func (a *SymbolAssigner) Reserve(symbol string, pc db.PaletteColor) bool {
+	if symbol == "" || a.reserved[symbol] || !AllowedSymbol(symbol) {
+		return false
+	}
+	family := FamilyCombined
+	for i, g := range a.glyphs {
+		if g.Symbol != symbol {
+			continue
+		}
+		if a.used[i] {
+			return false
+		}
+		a.used[i] = true
+		family = g.Family
+		break
+	}
+	a.reserved[symbol] = true
+	a.assigned = append(a.assigned, assignedColor{color: pc, family: family})
+	return true
+}
+
+// Assign выдаёт символ для цвета pc. Если среди уже обработанных есть похожие цвета,
+// выбирается первый свободный символ из группы, которой у них нет.
+func (a *SymbolAssigner) Assign(pc db.PaletteColor) string {
+	// 1. Собираем группы символов у похожих цветов
+	forbidden := map[GlyphFamily]bool{}
+	l1, a1, b1 := pc.Color.Lab()
+	for _, ac := range a.assigned {
+		l2, a2, b2 := ac.color.Color.Lab()
+		if euclideanDistanceLab([3]float64{l1, a1, b1}, [3]float64{l2, a2, b2}) < similarColorDist*similarColorDist {
+			forbidden[ac.family] = true
+		}
+	}
+
+	// 2. Ищем свободный символ из разрешённой группы, иначе — любой свободный
+	pick := -1
+	for i, g := range a.glyphs {
+		if a.used[i] {
+			continue
+		}
+		if !forbidden[g.Family] {
+			pick = i
+			break
+		}
+		if pick < 0 {
+			pick = i
+		}
+	}
+
+	// 3. Основной набор закончился — выдаём составной символ
+	var g Glyph
+	if pick >= 0 {
+		a.used[pick] = true
+		g = a.glyphs[pick]
+	} else {
+		// составные символы, закреплённые через Reserve, пропускаем
+		for a.reserved[comboSymbol(a.nextCombo)] {
+			a.nextCombo++
+		}
+		g = Glyph{Symbol: comboSymbol(a.nextCombo), Family: FamilyCombined}
+		a.nextCombo++
+	}
+	a.assigned = append(a.assigned, assignedColor{color: pc, family: g.Family})
+	return g.Symbol
+}
+
+// AssignSymbolsToMatched назначает каждому цвету уникальный символ.
+// Самые частые цвета получают символы первыми, похожие цвета — символы разной формы.
+func AssignSymbolsToMatched(matched [][]db.PaletteColor, glyphs []Glyph) {
+	// 1. Считаем, сколько раз встречается каждый цвет
+	counts := map[string]int{}
+	colors := map[string]db.PaletteColor{}
+	for y := range matched {
+		for x := range matched[y] {
+			pc := matched[y][x]
+			if pc.DMCCode == "BLANK" {
+				continue
+			}
+			counts[pc.DMCCode]++
+			colors[pc.DMCCode] = pc
+		}
+	}
+
+	// 2. Упорядочиваем коды по убыванию частоты (при равенстве — по коду)
+	codes := make([]string, 0, len(counts))
+	for code := range counts {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		if counts[codes[i]] != counts[codes[j]] {
+			return counts[codes[i]] > counts[codes[j]]
+		}
+		return codes[i] < codes[j]
+	})
+
+	// 3. Раздаём символы: сначала закреплённые за цветами, затем остальные
+	assigner := NewSymbolAssigner(glyphs)
+	symbolMap := make(map[string]string, len(codes)) // DMC -> символ
+	for _, code := range codes {
+		if pc := colors[code]; assigner.Reserve(pc.PreferredSymbol, pc) {
+			symbolMap[code] = pc.PreferredSymbol
+		}
+	}
+	for _, code := range codes {
+		if _, ok := symbolMap[code]; !ok {
+			symbolMap[code] = assigner.Assign(colors[code])
+		}
+	}
+
+	// 4. Проставляем символы в сетку
+	for y := range matched {
+		for x := range matched[y] {
+			pc := &matched[y][x]
+			if sym, ok := symbolMap[pc.DMCCode]; ok {
+				pc.Symbol = sym
+			}
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package image
+
+import (
+	"fmt"
+	"testing"
+
+	"diamond-mosaic/internal/db"
+
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+func TestSymbolGlyphsDistinct(t *testing.T) {
+	seen := map[string]bool{}
+	for _, g := range symbolGlyphs {
+		if seen[g.Symbol] {
+			t.Errorf("символ %q повторяется", g.Symbol)
+		}
+		seen[g.Symbol] = true
+	}
+	// Символы, исключённые из набора как легко путаемые
+	for _, s := range []string{"l", "I", "1", "|", "O", "0", "o", ",", ".", "'", `"`, "8", "5", "2", "Δ", "Д", "◎", "◉", "c", "s", "v"} {
+		if seen[s] {
+			t.Errorf("в наборе остался путаемый символ %q", s)
+		}
+	}
+}
+
+func TestComboSymbol(t *testing.T) {
+	base := len(comboAlphabet)
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "AA"},
+		{1, "AB"},
+		{base, "BA"},
+		{base*base - 1, "ZZ"},
+		{base * base, "AAA"},
+	}
+	for _, tt := range tests {
+		if got := comboSymbol(tt.n); got != tt.want {
+			t.Errorf("comboSymbol(%d) = %q, ожидалось %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestAllowedSymbol(t *testing.T) {
+	tests := []struct {
+		symbol string
+		want   bool
+	}{
+		{"★", true},
+		{"A", true},
+		{"AB", true},
+		{"ABC", true},
+		{"", false},
+		{"I", false},
+		{"l", false},
+		{"1", false},
+		{"0", false},
+		{"O", false},
+		{"|", false},
+		{"8", false},
+		{"A1", false},
+		{"AI", false},
+	}
+	for _, tt := range tests {
+		if got := AllowedSymbol(tt.symbol); got != tt.want {
+			t.Errorf("AllowedSymbol(%q) = %v, ожидалось %v", tt.symbol, got, tt.want)
+		}
+	}
+}
+
+// testColor создаёт цвет палитры с кодом code и серым уровнем v.
+func testColor(code string, v float64) db.PaletteColor {
+	return db.PaletteColor{DMCCode: code, Color: colorful.Color{R: v, G: v, B: v}}
+}
+
+// TestAssignSymbolsUnique проверяет, что каждый цвет получает свой символ, в том числе
+// когда цветов больше, чем символов в наборе, и самый частый цвет получает первый символ.
+func TestAssignSymbolsUnique(t *testing.T) {
+	n := len(symbolGlyphs) + 40
+	matched := make([][]db.PaletteColor, 1)
+	for i := 0; i < n; i++ {
+		pc := testColor(fmt.Sprint(i), float64(i)/float64(n))
+		matched[0] = append(matched[0], pc)
+		if i == n-1 {
+			// самый частый цвет
+			matched[0] = append(matched[0], pc, pc)
+		}
+	}
+	matched[0] = append(matched[0], BlankColor())
+
+	AssignSymbolsToMatched(matched, symbolGlyphs)
+
+	symbols := map[string]string{} // символ -> код
+	for _, pc := range matched[0] {
+		if pc.DMCCode == "BLANK" {
+			if pc.Symbol != "" {
+				t.Errorf("пустой клетке выдан символ %q", pc.Symbol)
+			}
+			continue
+		}
+		if code, ok := symbols[pc.Symbol]; ok && code != pc.DMCCode {
+			t.Fatalf("символ %q выдан цветам %s и %s", pc.Symbol, code, pc.DMCCode)
+		}
+		symbols[pc.Symbol] = pc.DMCCode
+	}
+	if len(symbols) != n {
+		t.Errorf("выдано %d символов, ожидалось %d", len(symbols), n)
+	}
+	if got := matched[0][n-1].Symbol; got != symbolGlyphs[0].Symbol {
+		t.Errorf("самый частый цвет получил %q, ожидалось %q", got, symbolGlyphs[0].Symbol)
+	}
+}
+
+// TestAssignSymbolsSimilarColors проверяет, что похожие цвета получают символы разных групп.
+func TestAssignSymbolsSimilarColors(t *testing.T) {
+	a := NewSymbolAssigner(symbolGlyphs)
+	first := a.Assign(testColor("1", 0.50))
+	second := a.Assign(testColor("2", 0.51))
+	third := a.Assign(testColor("3", 0.95)) // непохожий цвет
+	family := func(s string) GlyphFamily {
+		for _, g := range symbolGlyphs {
+			if g.Symbol == s {
+				return g.Family
+			}
+		}
+		return FamilyCombined
+	}
+	if family(first) == family(second) {
+		t.Errorf("похожие цвета получили символы одной группы: %q и %q", first, second)
+	}
+	if family(third) != family(first) {
+		t.Errorf("непохожий цвет получил %q, ожидался символ группы первого цвета", third)
+	}
+}
+
+func TestAssignSymbolsPreferred(t *testing.T) {
+	pinned := func(code string, v float64, symbol string) db.PaletteColor {
+		pc := testColor(code, v)
+		pc.PreferredSymbol = symbol
+		return pc
+	}
+	matched := [][]db.PaletteColor{{
+		pinned("1", 0.1, "★"),
+		pinned("2", 0.3, "★"), // символ уже занят
+		pinned("3", 0.5, "I"), // недопустимый символ
+		pinned("4", 0.7, "AA"),
+		testColor("5", 0.9),
+	}}
+	AssignSymbolsToMatched(matched, symbolGlyphs[:1]) // основной набор — один символ
+
+	got := map[string]string{}
+	for _, pc := range matched[0] {
+		got[pc.DMCCode] = pc.Symbol
+	}
+	if got["1"] != "★" || got["4"] != "AA" {
+		t.Errorf("закреплённые символы не выданы: %v", got)
+	}
+	if got["2"] == "★" || got["3"] == "I" {
+		t.Errorf("занятый или недопустимый символ выдан повторно: %v", got)
+	}
+	seen := map[string]bool{}
+	for code, s := range got {
+		if seen[s] {
+			t.Errorf("символ %q выдан нескольким цветам: %v", s, got)
+		}
+		seen[s] = true
+		if s == "" {
+			t.Errorf("цвету %s не выдан символ", code)
+		}
+	}
+}
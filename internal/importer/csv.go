@@ -0,0 +1,75 @@
+package importer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"diamond-mosaic/internal/db"
+)
+
+// ReadCSV читает CSV-сетку: одна строка файла — один ряд схемы, одна ячейка — одна клетка.
+// В ячейке — код DMC или цвет #RRGGBB; пустая ячейка — пустая клетка.
+// Разделитель (запятая, точка с запятой или табуляция) определяется по первой строке.
+// Этот же формат выдаёт экспорт output=csv.
+// all — полная палитра, см. Import.
+func ReadCSV(r io.Reader, palette, all []db.PaletteColor) (*Pattern, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения CSV: %w", err)
+	}
+	cr := csv.NewReader(strings.NewReader(string(data)))
+	cr.Comma = detectComma(string(data))
+	cr.FieldsPerRecord = -1 // короткие строки дополняются пустыми клетками
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка разбора CSV: %w", err)
+	}
+
+	width := 0
+	for _, rec := range records {
+		if len(rec) > width {
+			width = len(rec)
+		}
+	}
+	grid, err := newGrid(width, len(records))
+	if err != nil {
+		return nil, err
+	}
+
+	mapper := newColorMapper(palette, all)
+	for y, rec := range records {
+		for x, cell := range rec {
+			cell = strings.TrimSpace(cell)
+			if cell == "" || strings.EqualFold(cell, "BLANK") {
+				continue
+			}
+			c, isHex := parseHexColor(cell)
+			if !strings.HasPrefix(cell, "#") {
+				isHex = false // "310310" — это код, а не цвет
+			}
+			pc, err := mapper.lookup(cell, c, isHex)
+			if err != nil {
+				return nil, fmt.Errorf("строка %d, столбец %d: %w", y+1, x+1, err)
+			}
+			grid[y][x] = pc
+		}
+	}
+	return &Pattern{Matched: grid, Substitutions: mapper.report()}, nil
+}
+
+// detectComma выбирает разделитель, который чаще всего встречается в первой строке.
+func detectComma(data string) rune {
+	first := data
+	if i := strings.IndexByte(data, '\n'); i >= 0 {
+		first = data[:i]
+	}
+	best, bestCount := ',', strings.Count(first, ",")
+	for _, c := range []rune{';', '\t'} {
+		if n := strings.Count(first, string(c)); n > bestCount {
+			best, bestCount = c, n
+		}
+	}
+	return best
+}
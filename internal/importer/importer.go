@@ -0,0 +1,154 @@
+// Package importer переводит схемы из других программ для алмазной мозаики
+// и вышивки крестом во внутреннюю сетку [][]db.PaletteColor.
+//
+// Поддерживаются OXS (Open Cross Stitch XML) и простая CSV-сетка кодов.
+// Коды, которых нет в рабочей палитре, заменяются ближайшим по Lab цветом палитры,
+// если цвет кода известен: из полной палитры DMC или из самого файла.
+package importer
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"diamond-mosaic/internal/db"
+	imagepkg "diamond-mosaic/internal/image"
+
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// Format — формат импортируемого файла.
+type Format string
+
+const (
+	FormatOXS Format = "oxs"
+	FormatCSV Format = "csv"
+)
+
+// maxCells — ограничение на размер импортируемой сетки (по каждой стороне).
+const maxCells = 2000
+
+// Substitution — код из файла, которого нет в палитре, и цвет палитры, которым он заменён.
+type Substitution struct {
+	SourceCode string
+	Palette    db.PaletteColor
+}
+
+// Pattern — импортированная схема.
+type Pattern struct {
+	Matched       [][]db.PaletteColor // сетка цветов палитры, Matched[y][x]
+	Title         string              // название схемы из файла, если есть
+	Substitutions []Substitution      // замены неизвестных кодов
+}
+
+// DetectFormat определяет формат по явному значению или по расширению имени файла.
+func DetectFormat(explicit, fileName string) (Format, error) {
+	f := Format(strings.ToLower(strings.TrimSpace(explicit)))
+	if f == "" {
+		f = Format(strings.TrimPrefix(strings.ToLower(path.Ext(fileName)), "."))
+	}
+	switch f {
+	case FormatOXS, "xml":
+		return FormatOXS, nil
+	case FormatCSV, "txt":
+		return FormatCSV, nil
+	}
+	return "", fmt.Errorf("неизвестный формат схемы %q", f)
+}
+
+// Import читает схему в формате f и сопоставляет её цвета с палитрой palette.
+// all — все цвета DMC до отбора различимых (db.FilterPalette): по ним определяется цвет
+// кода, выпавшего из рабочей палитры; nil — только palette.
+func Import(r io.Reader, f Format, palette, all []db.PaletteColor) (*Pattern, error) {
+	if len(palette) == 0 {
+		return nil, fmt.Errorf("палитра пуста")
+	}
+	switch f {
+	case FormatOXS:
+		return ReadOXS(r, palette, all)
+	case FormatCSV:
+		return ReadCSV(r, palette, all)
+	}
+	return nil, fmt.Errorf("неизвестный формат схемы %q", f)
+}
+
+// colorMapper сопоставляет коды из файла с палитрой и запоминает замены.
+type colorMapper struct {
+	byCode        map[string]db.PaletteColor // рабочая палитра
+	allByCode     map[string]db.PaletteColor // полная палитра: известные цвета кодов
+	palette       []db.PaletteColor
+	substitutions map[string]db.PaletteColor
+}
+
+// newColorMapper индексирует рабочую и полную палитры по коду DMC.
+func newColorMapper(palette, all []db.PaletteColor) *colorMapper {
+	m := &colorMapper{
+		byCode:        make(map[string]db.PaletteColor, len(palette)),
+		allByCode:     make(map[string]db.PaletteColor, len(all)),
+		palette:       palette,
+		substitutions: map[string]db.PaletteColor{},
+	}
+	for _, pc := range palette {
+		m.byCode[normalizeCode(pc.DMCCode)] = pc
+	}
+	for _, pc := range all {
+		m.allByCode[normalizeCode(pc.DMCCode)] = pc
+	}
+	return m
+}
+
+// lookup возвращает цвет палитры для кода. Если кода нет в рабочей палитре, выбирается
+// ближайший к его цвету цвет палитры; цвет берётся из полной палитры, а для неизвестного
+// ей кода — из файла (known == true).
+func (m *colorMapper) lookup(code string, c colorful.Color, known bool) (db.PaletteColor, error) {
+	if pc, ok := m.byCode[normalizeCode(code)]; ok {
+		return pc, nil
+	}
+	if pc, ok := m.substitutions[code]; ok {
+		return pc, nil
+	}
+	if pc, ok := m.allByCode[normalizeCode(code)]; ok {
+		c, known = pc.Color, true
+	}
+	if !known {
+		return db.PaletteColor{}, fmt.Errorf("код %q отсутствует в палитре, а его цвет неизвестен", code)
+	}
+	pc := imagepkg.NearestColor(c, m.palette)
+	m.substitutions[code] = pc
+	return pc, nil
+}
+
+// report возвращает список замен, отсортированный по исходному коду.
+func (m *colorMapper) report() []Substitution {
+	subs := make([]Substitution, 0, len(m.substitutions))
+	for code, pc := range m.substitutions {
+		subs = append(subs, Substitution{SourceCode: code, Palette: pc})
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].SourceCode < subs[j].SourceCode })
+	return subs
+}
+
+// normalizeCode приводит код к виду палитры: "DMC 310" -> "310", " b5200 " -> "B5200".
+func normalizeCode(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	code = strings.TrimPrefix(code, "DMC")
+	return strings.TrimSpace(code)
+}
+
+// newGrid создаёт сетку w×h, заполненную пустыми клетками.
+func newGrid(w, h int) ([][]db.PaletteColor, error) {
+	if w <= 0 || h <= 0 || w > maxCells || h > maxCells {
+		return nil, fmt.Errorf("некорректный размер схемы %dx%d", w, h)
+	}
+	blank := imagepkg.BlankColor()
+	grid := make([][]db.PaletteColor, h)
+	for y := range grid {
+		grid[y] = make([]db.PaletteColor, w)
+		for x := range grid[y] {
+			grid[y][x] = blank
+		}
+	}
+	return grid, nil
+}
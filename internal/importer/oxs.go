@@ -0,0 +1,76 @@
+package importer
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"diamond-mosaic/internal/db"
+	"diamond-mosaic/internal/oxs"
+
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// ReadOXS читает OXS-файл. Учитываются только полные крестики: каждый становится алмазом,
+// клетки без крестиков и крестики цвета ткани остаются пустыми. all — полная палитра, см. Import.
+func ReadOXS(r io.Reader, palette, all []db.PaletteColor) (*Pattern, error) {
+	var chart oxs.Chart
+	if err := xml.NewDecoder(r).Decode(&chart); err != nil {
+		return nil, fmt.Errorf("ошибка разбора OXS: %w", err)
+	}
+	grid, err := newGrid(chart.Properties.Width, chart.Properties.Height)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. Сопоставляем палитру файла с нашей
+	mapper := newColorMapper(palette, all)
+	colors := map[int]db.PaletteColor{}
+	for _, item := range chart.Palette {
+		if item.Index == oxs.ClothIndex || strings.EqualFold(item.Number, "cloth") {
+			continue // ткань
+		}
+		c, known := parseHexColor(item.Color)
+		pc, err := mapper.lookup(item.Number, c, known)
+		if err != nil {
+			return nil, fmt.Errorf("палитра OXS, элемент %d: %w", item.Index, err)
+		}
+		colors[item.Index] = pc
+	}
+
+	// 2. Раскладываем крестики по сетке
+	for _, st := range chart.FullStitches {
+		if st.X < 0 || st.Y < 0 || st.X >= chart.Properties.Width || st.Y >= chart.Properties.Height {
+			return nil, fmt.Errorf("крестик (%d, %d) вне схемы", st.X, st.Y)
+		}
+		if st.PalIndex == oxs.ClothIndex {
+			continue
+		}
+		pc, ok := colors[st.PalIndex]
+		if !ok {
+			return nil, fmt.Errorf("крестик (%d, %d): неизвестный индекс палитры %d", st.X, st.Y, st.PalIndex)
+		}
+		grid[st.Y][st.X] = pc
+	}
+
+	return &Pattern{Matched: grid, Title: chart.Properties.Title, Substitutions: mapper.report()}, nil
+}
+
+// parseHexColor разбирает цвет вида RRGGBB или #RRGGBB.
+func parseHexColor(s string) (colorful.Color, bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(s) != 6 {
+		return colorful.Color{}, false
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return colorful.Color{}, false
+	}
+	return colorful.Color{
+		R: float64(v>>16&0xff) / 255.0,
+		G: float64(v>>8&0xff) / 255.0,
+		B: float64(v&0xff) / 255.0,
+	}, true
+}
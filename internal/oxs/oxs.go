@@ -0,0 +1,62 @@
+// Package oxs описывает XML-структуру формата OXS (Open Cross Stitch),
+// который понимают распространённые программы для просмотра схем вышивки.
+package oxs
+
+import "encoding/xml"
+
+// Version — версия формата, которую записывает экспорт.
+const Version = "1.0"
+
+// ClothIndex — индекс элемента палитры, обозначающего ткань (фон).
+const ClothIndex = 0
+
+// Chart — корневой элемент OXS-файла.
+type Chart struct {
+	XMLName      xml.Name      `xml:"chart"`
+	Format       Format        `xml:"format"`
+	Properties   Properties    `xml:"properties"`
+	Palette      []PaletteItem `xml:"palette>palette_item"`
+	FullStitches []Stitch      `xml:"fullstitches>stitch"`
+	PartStitches struct{}      `xml:"partstitches"`
+	BackStitches struct{}      `xml:"backstitches"`
+	Ornaments    struct{}      `xml:"ornaments_inc_knots_and_beads"`
+	CommentBoxes struct{}      `xml:"commentboxes"`
+}
+
+// Format — служебный элемент с комментариями о программе, создавшей файл.
+type Format struct {
+	Comments01 string `xml:"comments01,attr,omitempty"`
+	Comments02 string `xml:"comments02,attr,omitempty"`
+}
+
+// Properties — свойства схемы.
+type Properties struct {
+	OXSVersion      string `xml:"oxsversion,attr"`
+	Software        string `xml:"software,attr,omitempty"`
+	SoftwareVersion string `xml:"software_version,attr,omitempty"`
+	Width           int    `xml:"chartwidth,attr"`
+	Height          int    `xml:"chartheight,attr"`
+	Title           string `xml:"charttitle,attr"`
+	Author          string `xml:"author,attr"`
+	Copyright       string `xml:"copyright,attr"`
+	Instructions    string `xml:"instructions,attr"`
+	StitchesPerInch string `xml:"stitchesperinch,attr,omitempty"`
+	PaletteCount    int    `xml:"palettecount,attr"`
+}
+
+// PaletteItem — элемент палитры. Элемент с индексом ClothIndex — ткань.
+type PaletteItem struct {
+	Index   int    `xml:"index,attr"`
+	Number  string `xml:"number,attr"` // например "DMC 310"
+	Name    string `xml:"name,attr"`
+	Color   string `xml:"color,attr"` // RRGGBB
+	Symbol  string `xml:"symbol,attr,omitempty"`
+	Strands string `xml:"strands,attr,omitempty"`
+}
+
+// Stitch — полный крестик в клетке (X, Y) цветом PalIndex.
+type Stitch struct {
+	X        int `xml:"x,attr"`
+	Y        int `xml:"y,attr"`
+	PalIndex int `xml:"palindex,attr"`
+}
@@ -0,0 +1,118 @@
+package pdf
+
+import (
+	"fmt"
+	"strconv"
+
+	"diamond-mosaic/internal/bom"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// printBOM добавляет страницу с комплектацией набора: пакетики по цветам, холст,
+// инструменты, упаковка и итоговая стоимость. Если цены не заданы, столбцы с ценами не выводятся.
+func printBOM(pdf *gofpdf.Fpdf, kit *bom.BOM) {
+	// 1. Разметка таблицы
+	const (
+		marginTop    = 15.0
+		marginLeft   = 10.0
+		bottomMargin = 15.0
+		rowH         = 5.5
+	)
+	withPrices := kit.Total > 0
+	type column struct {
+		title string
+		width float64
+		align string
+	}
+	cols := []column{
+		{"Код DMC", 22, "L"},
+		{"Название", 58, "L"},
+		{"Символ", 15, "C"},
+		{"Алмазов", 20, "R"},
+		{"С запасом", 22, "R"},
+		{"Пакетов", 18, "R"},
+	}
+	if withPrices {
+		cols = append(cols, column{"Цена", 17, "R"}, column{"Сумма", 18, "R"})
+	}
+
+	_, pageH := pdf.GetPageSize()
+	row := func(cells []string) {
+		if pdf.GetY()+rowH > pageH-bottomMargin {
+			pdf.AddPage()
+			pdf.SetY(marginTop)
+		}
+		pdf.SetX(marginLeft)
+		for i, c := range cols {
+			pdf.CellFormat(c.width, rowH, cells[i], "B", 0, c.align, false, 0, "")
+		}
+		pdf.Ln(rowH)
+	}
+	header := func() {
+		titles := make([]string, len(cols))
+		for i, c := range cols {
+			titles[i] = c.title
+		}
+		pdf.SetTextColor(90, 90, 90)
+		row(titles)
+		pdf.SetTextColor(0, 0, 0)
+	}
+
+	// 2. Заголовок и параметры расчёта
+	pdf.AddPage()
+	pdf.SetFont("DejaVu", "", 12)
+	pdf.SetTextColor(60, 70, 160)
+	pdf.SetXY(marginLeft, marginTop-5)
+	pdf.CellFormat(0, 7, "Комплектация набора", "", 1, "L", false, 0, "")
+	pdf.SetFont("DejaVu", "", 8)
+	pdf.SetTextColor(90, 90, 90)
+	pdf.SetX(marginLeft)
+	pdf.CellFormat(0, 5, fmt.Sprintf("Пакетик — %d алмазов, запас %s%%", kit.Settings.BagSize, formatNumber(kit.Settings.SparePercent)), "", 1, "L", false, 0, "")
+	pdf.Ln(2)
+
+	// 3. Пакетики по цветам
+	pdf.SetFont("DejaVu", "", 8)
+	header()
+	for _, c := range kit.Colors {
+		cells := []string{c.DMCCode, c.Name, c.Symbol,
+			strconv.Itoa(c.Drills), strconv.Itoa(c.WithSpare), strconv.Itoa(c.Bags)}
+		if withPrices {
+			cells = append(cells, formatMoney(c.BagPrice), formatMoney(c.Cost))
+		}
+		row(cells)
+	}
+	totals := []string{"", "Итого алмазов", "", strconv.Itoa(kit.TotalDrills), "", strconv.Itoa(kit.TotalBags)}
+	if withPrices {
+		totals = append(totals, "", formatMoney(kit.ColorsCost))
+	}
+	row(totals)
+	pdf.Ln(4)
+
+	// 4. Холст, инструменты, упаковка и итог
+	pdf.SetX(marginLeft)
+	for _, it := range kit.Items {
+		line := fmt.Sprintf("%s: %s %s", it.Name, formatNumber(it.Quantity), it.Unit)
+		if withPrices {
+			line += fmt.Sprintf(" — %s %s", formatMoney(it.Cost), kit.Settings.Currency)
+		}
+		pdf.SetX(marginLeft)
+		pdf.CellFormat(0, rowH, line, "", 1, "L", false, 0, "")
+	}
+	if withPrices {
+		pdf.Ln(2)
+		pdf.SetFont("DejaVu", "", 11)
+		pdf.SetX(marginLeft)
+		pdf.CellFormat(0, 7, fmt.Sprintf("Стоимость набора: %s %s", formatMoney(kit.Total), kit.Settings.Currency), "", 1, "L", false, 0, "")
+	}
+}
+
+// formatMoney печатает сумму с двумя знаками после запятой.
+func formatMoney(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// formatNumber печатает число без лишних нулей после запятой.
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -6,13 +6,18 @@ import (
 	"image"
 	"image/png"
 
+	"diamond-mosaic/fonts"
+	"diamond-mosaic/internal/bom"
 	imagepkg "diamond-mosaic/internal/image"
 
+
 	"github.com/jung-kurt/gofpdf"
 )
 
-// GeneratePDF формирует PDF-файл с мозаикой и легендой.
-func GeneratePDF(mosaicImg image.Image, usages []imagepkg.ColorUsage, sizeInfo imagepkg.MosaicSizeInfo) ([]byte, error) {
+// GeneratePDF формирует PDF-файл с мозаикой и легендой. Если есть замены цветов
+// из-за складских остатков (substitutions), добавляется страница с отчётом о них,
+// если задана комплектация набора (kit) — страница с ней.
+func GeneratePDF(mosaicImg image.Image, usages []imagepkg.ColorUsage, sizeInfo imagepkg.MosaicSizeInfo, substitutions []imagepkg.Substitution, kit *bom.BOM) ([]byte, error) {
 	// 1. Кодируем картинку-мозаику в PNG-буфер
 	var imgBuf bytes.Buffer
 	if err := png.Encode(&imgBuf, mosaicImg); err != nil {
@@ -31,6 +36,7 @@ func GeneratePDF(mosaicImg image.Image, usages []imagepkg.ColorUsage, sizeInfo i
 		marginRight     = 10.0
 	)
 	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes("DejaVu", "", fonts.DejaVuSans)
 	pdf.AddPage()
 	pageW, pageH := pdf.GetPageSize()
 
@@ -92,7 +98,6 @@ func GeneratePDF(mosaicImg image.Image, usages []imagepkg.ColorUsage, sizeInfo i
 		pdf.Rect(xPos, yPos, squareSize, squareSize, "F")
 
 		// === Символ по центру квадратика ===
-		pdf.SetFont("Arial", "B", 10) // жирный шрифт, размер 10
 		// Определяем яркость цвета фона
 		l, _, _ := u.PaletteColor.Color.Lab()
 		if l > 0.5 {
@@ -101,15 +106,15 @@ func GeneratePDF(mosaicImg image.Image, usages []imagepkg.ColorUsage, sizeInfo i
 			pdf.SetTextColor(255, 255, 255) // тёмный фон → белый текст
 		}
 
+		// Тот же шрифт и та же разметка, что и в PNG: кегль и смещения в мм
 		symbol := u.PaletteColor.Symbol
-		fontSize := 10.0 // размер шрифта, должен совпадать с SetFont
-		// Получаем ширину символа (в миллиметрах)
-		symbolWidth := pdf.GetStringWidth(symbol)
-		// X — по центру квадрата
-		symbolX := xPos + (squareSize-symbolWidth)/2
-		// Y — по центру квадрата (подбор вручную для красоты)
-		symbolY := yPos + (squareSize+fontSize)/2 - 3.5
-		pdf.Text(symbolX, symbolY, symbol)
+		size, dx, dy, err := fonts.FitSymbol(symbol, squareSize, imagepkg.SymbolFill)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка измерения символа %q: %v", symbol, err)
+		}
+		pdf.SetFont("DejaVu", "", 0)
+		pdf.SetFontUnitSize(size)
+		pdf.Text(xPos+dx, yPos+dy, symbol)
 
 		// Вернуть обычный шрифт для текста справа
 		pdf.SetFont("Arial", "", 8)
@@ -128,6 +133,18 @@ func GeneratePDF(mosaicImg image.Image, usages []imagepkg.ColorUsage, sizeInfo i
 		}
 	}
 
+	// 8. Отчёт о заменах цветов
+	if len(substitutions) > 0 {
+		if err := printSubstitutions(pdf, substitutions, usages); err != nil {
+			return nil, err
+		}
+	}
+
+	// 9. Комплектация и стоимость набора
+	if kit != nil {
+		printBOM(pdf, kit)
+	}
+
 	// Возврат готового PDF как []byte
 	var pdfBuf bytes.Buffer
 	if err := pdf.Output(&pdfBuf); err != nil {
@@ -138,7 +155,6 @@ func GeneratePDF(mosaicImg image.Image, usages []imagepkg.ColorUsage, sizeInfo i
 
 // printMosaicSizes выводит текст с размерами над изображением
 func printMosaicSizes(pdf *gofpdf.Fpdf, size imagepkg.MosaicSizeInfo, pageW float64, pageMarginTop float64) float64 {
-	pdf.AddUTF8Font("DejaVu", "", "fonts/DejaVuSans.ttf")
 	pdf.SetFont("DejaVu", "", 12)
 	pdf.SetTextColor(60, 70, 160)
 	baseStr := fmt.Sprintf(
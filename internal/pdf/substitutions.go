@@ -0,0 +1,126 @@
+package pdf
+
+import (
+	"fmt"
+
+	"diamond-mosaic/fonts"
+	imagepkg "diamond-mosaic/internal/image"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// substitutionReasons — подписи причин замены в отчёте.
+var substitutionReasons = map[imagepkg.SubstitutionReason]string{
+	imagepkg.ReasonOutOfStock: "нет на складе",
+	imagepkg.ReasonStockLimit: "не хватило остатка",
+}
+
+// printSubstitutions добавляет страницу с отчётом о заменах цветов из-за складских остатков.
+// Символ заменяющего цвета берётся из легенды (usages), как он напечатан на схеме.
+func printSubstitutions(pdf *gofpdf.Fpdf, subs []imagepkg.Substitution, usages []imagepkg.ColorUsage) error {
+	// 1. Разметка таблицы: образец и код нужного цвета, образец, символ и код замены, количество, причина
+	const (
+		marginTop    = 15.0
+		marginLeft   = 10.0
+		bottomMargin = 15.0
+		rowH         = 7.0
+		squareSize   = 5.0
+	)
+	cols := []struct {
+		title string
+		width float64
+	}{
+		{"Нужный цвет", 65},
+		{"Заменён на", 65},
+		{"Алмазов", 25},
+		{"Причина", 35},
+	}
+
+	symbols := make(map[string]string, len(usages))
+	for _, u := range usages {
+		symbols[u.PaletteColor.DMCCode] = u.PaletteColor.Symbol
+	}
+
+	_, pageH := pdf.GetPageSize()
+	y := marginTop
+	header := func() {
+		pdf.SetFont("DejaVu", "", 9)
+		pdf.SetTextColor(90, 90, 90)
+		x := marginLeft
+		for _, c := range cols {
+			pdf.Text(x, y, c.title)
+			x += c.width
+		}
+		y += 3
+	}
+
+	// 2. Заголовок страницы
+	pdf.AddPage()
+	pdf.SetFont("DejaVu", "", 12)
+	pdf.SetTextColor(60, 70, 160)
+	pdf.Text(marginLeft, y, "Замены цветов из-за складских остатков")
+	y += 8
+	header()
+
+	// 3. Строки отчёта
+	for _, s := range subs {
+		if y+rowH > pageH-bottomMargin {
+			pdf.AddPage()
+			y = marginTop
+			header()
+		}
+		x := marginLeft
+		swatch(pdf, x, y, squareSize, s.From.Color)
+		pdf.SetFont("DejaVu", "", 9)
+		pdf.SetTextColor(0, 0, 0)
+		pdf.Text(x+squareSize+2, y+squareSize-1, fmt.Sprintf("%s %s", s.From.DMCCode, s.From.Name))
+		x += cols[0].width
+
+		swatch(pdf, x, y, squareSize, s.To.Color)
+		if symbol := symbols[s.To.DMCCode]; symbol != "" {
+			if err := swatchSymbol(pdf, x, y, squareSize, s.To.Color, symbol); err != nil {
+				return err
+			}
+		}
+		pdf.SetFont("DejaVu", "", 9)
+		pdf.SetTextColor(0, 0, 0)
+		pdf.Text(x+squareSize+2, y+squareSize-1, fmt.Sprintf("%s %s", s.To.DMCCode, s.To.Name))
+		x += cols[1].width
+
+		pdf.Text(x, y+squareSize-1, fmt.Sprintf("%d", s.Count))
+		x += cols[2].width
+		reason := substitutionReasons[s.Reason]
+		if reason == "" {
+			reason = string(s.Reason)
+		}
+		pdf.Text(x, y+squareSize-1, reason)
+		y += rowH
+	}
+	return nil
+}
+
+// swatch рисует квадрат-образец цвета c.
+func swatch(pdf *gofpdf.Fpdf, x, y, size float64, c colorful.Color) {
+	r, g, b := c.RGB255()
+	pdf.SetFillColor(int(r), int(g), int(b))
+	pdf.SetDrawColor(150, 150, 150)
+	pdf.Rect(x, y, size, size, "FD")
+}
+
+// swatchSymbol печатает символ поверх образца так же, как в легенде.
+func swatchSymbol(pdf *gofpdf.Fpdf, x, y, size float64, c colorful.Color, symbol string) error {
+	fontSize, dx, dy, err := fonts.FitSymbol(symbol, size, imagepkg.SymbolFill)
+	if err != nil {
+		return fmt.Errorf("ошибка измерения символа %q: %v", symbol, err)
+	}
+	if l, _, _ := c.Lab(); l > 0.5 {
+		pdf.SetTextColor(0, 0, 0)
+	} else {
+		pdf.SetTextColor(255, 255, 255)
+	}
+	pdf.SetFont("DejaVu", "", 0)
+	pdf.SetFontUnitSize(fontSize)
+	pdf.Text(x+dx, y+dy, symbol)
+	return nil
+}
@@ -0,0 +1,100 @@
+package scheme
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"diamond-mosaic/internal/db"
+)
+
+// PostgresStore хранит схемы в таблице schemes (см. db.SchemeRepository):
+// параметры генерации, ссылку на исходник (SHA-256), легенду и сетку в формате .dmscheme.
+type PostgresStore struct {
+	repo *db.SchemeRepository
+}
+
+// NewPostgresStore создаёт хранилище схем поверх репозитория.
+func NewPostgresStore(repo *db.SchemeRepository) *PostgresStore {
+	return &PostgresStore{repo: repo}
+}
+
+// Save сохраняет схему и возвращает её новый идентификатор.
+func (p *PostgresStore) Save(s *Scheme) (string, error) {
+	id, err := NewID()
+	if err != nil {
+		return "", err
+	}
+	params, err := json.Marshal(s.Params)
+	if err != nil {
+		return "", err
+	}
+	legend, err := json.Marshal(s.Legend())
+	if err != nil {
+		return "", err
+	}
+	var grid bytes.Buffer
+	if err := Write(&grid, s, true); err != nil {
+		return "", fmt.Errorf("ошибка записи схемы: %w", err)
+	}
+
+	sum := s.Summary(id)
+	rec := &db.SchemeRecord{
+		SchemeSummary: db.SchemeSummary{
+			ID:             id,
+			WidthCM:        sum.WidthCM,
+			HeightCM:       sum.HeightCM,
+			GridWidth:      sum.GridWidth,
+			GridHeight:     sum.GridHeight,
+			Colors:         sum.Colors,
+			Drills:         sum.Drills,
+			SourceSHA256:   sum.SourceSHA256,
+			PaletteVersion: sum.PaletteVersion,
+		},
+		Params: params,
+		Legend: legend,
+		Grid:   grid.Bytes(),
+	}
+	if err := p.repo.Create(context.Background(), rec); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Get читает схему по идентификатору.
+func (p *PostgresStore) Get(id string) (*Scheme, error) {
+	rec, err := p.repo.Get(context.Background(), id)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Read(bytes.NewReader(rec.Grid))
+}
+
+// List возвращает схемы от новых к старым.
+func (p *PostgresStore) List(limit, offset int) ([]Summary, error) {
+	rows, err := p.repo.List(context.Background(), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Summary, 0, len(rows))
+	for _, r := range rows {
+		list = append(list, Summary{
+			ID:             r.ID,
+			CreatedAt:      r.CreatedAt,
+			WidthCM:        r.WidthCM,
+			HeightCM:       r.HeightCM,
+			GridWidth:      r.GridWidth,
+			GridHeight:     r.GridHeight,
+			Colors:         r.Colors,
+			Drills:         r.Drills,
+			SourceSHA256:   r.SourceSHA256,
+			PaletteVersion: r.PaletteVersion,
+		})
+	}
+	return list, nil
+}
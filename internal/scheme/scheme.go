@@ -0,0 +1,291 @@
+// Package scheme описывает формат файла схемы .dmscheme и умеет его писать и читать.
+//
+// Файл — это JSON (по умолчанию сжатый gzip), в котором хранятся сетка подобранных
+// цветов, таблица цветов с символами, профиль алмаза, ссылка на версию палитры
+// и исходные параметры генерации. По файлу можно заново получить PDF или PNG
+// без исходной фотографии и без текущей палитры из БД.
+package scheme
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"diamond-mosaic/internal/db"
+	imagepkg "diamond-mosaic/internal/image"
+
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// FormatName — значение поля format, по которому файл опознаётся как схема.
+const FormatName = "dmscheme"
+
+// Version — текущая версия формата. Читаются файлы версий от 1 до Version.
+const Version = 1
+
+// Extension — расширение файла схемы.
+const Extension = ".dmscheme"
+
+// blankIndex — индекс пустой клетки в сетке.
+const blankIndex = -1
+
+// Scheme — содержимое файла .dmscheme.
+type Scheme struct {
+	Format    string                  `json:"format"`
+	Version   int                     `json:"version"`
+	CreatedAt time.Time               `json:"created_at"`
+	Palette   PaletteRef              `json:"palette"`
+	Drill     DrillProfile            `json:"drill"`
+	Params    imagepkg.Params         `json:"params"`
+	Size      imagepkg.MosaicSizeInfo `json:"size"`
+	Colors    []Color                 `json:"colors"` // таблица цветов и символов
+	Width     int                     `json:"width"`
+	Height    int                     `json:"height"`
+	Grid      [][]int                 `json:"grid"` // Grid[y][x] — индекс в Colors или -1 для пустой клетки
+
+	Substitutions []Substitution `json:"substitutions,omitempty"` // замены цветов из-за складских остатков
+}
+
+// PaletteRef — ссылка на палитру, по которой построена схема.
+type PaletteRef struct {
+	Version string `json:"version"` // db.PaletteVersion
+}
+
+// DrillProfile — параметры алмаза.
+type DrillProfile struct {
+	SizeMM float64 `json:"size_mm"`
+	Shape  string  `json:"shape"` // square или round
+}
+
+// Color — цвет схемы вместе с назначенным ему символом.
+type Color struct {
+	DMCCode string `json:"dmc_code"`
+	Name    string `json:"name"`
+	RGB     [3]int `json:"rgb"`
+	Symbol  string `json:"symbol"`
+}
+
+// Substitution — замена цвета из-за складских остатков (см. image.Substitution).
+type Substitution struct {
+	From   Color  `json:"from"`
+	To     Color  `json:"to"` // с символом, под которым цвет выложен на схеме
+	Count  int    `json:"count"`
+	Reason string `json:"reason"` // out_of_stock или stock_limit
+}
+
+// FromResult собирает схему из результата генерации.
+func FromResult(res imagepkg.Result) *Scheme {
+	s := &Scheme{
+		Format:    FormatName,
+		Version:   Version,
+		CreatedAt: time.Now().UTC(),
+		Palette:   PaletteRef{Version: res.Params.PaletteVersion},
+		Drill:     DrillProfile{SizeMM: res.Params.Options.DrillSizeMM, Shape: "square"},
+		Params:    res.Params,
+		Size:      res.Size,
+		Height:    len(res.Matched),
+	}
+	if s.Height > 0 {
+		s.Width = len(res.Matched[0])
+	}
+
+	// 1. Таблица цветов в порядке легенды
+	index := map[string]int{}
+	for _, u := range res.Usages {
+		if u.PaletteColor.DMCCode == "BLANK" {
+			continue
+		}
+		index[u.PaletteColor.DMCCode] = len(s.Colors)
+		s.Colors = append(s.Colors, newColor(u.PaletteColor))
+	}
+
+	// 2. Сетка индексов
+	s.Grid = make([][]int, s.Height)
+	for y, row := range res.Matched {
+		s.Grid[y] = make([]int, len(row))
+		for x, pc := range row {
+			i, ok := index[pc.DMCCode]
+			if !ok {
+				if pc.DMCCode != "BLANK" {
+					// цвета нет в легенде (Usages не пересчитаны) — добавляем
+					i = len(s.Colors)
+					index[pc.DMCCode] = i
+					s.Colors = append(s.Colors, newColor(pc))
+				} else {
+					i = blankIndex
+				}
+			}
+			s.Grid[y][x] = i
+		}
+	}
+
+	// 3. Замены цветов; символ берём из таблицы цветов, он назначен уже после замен
+	for _, sub := range res.Substitutions {
+		to := newColor(sub.To)
+		if i, ok := index[to.DMCCode]; ok {
+			to.Symbol = s.Colors[i].Symbol
+		}
+		s.Substitutions = append(s.Substitutions, Substitution{
+			From: newColor(sub.From), To: to, Count: sub.Count, Reason: string(sub.Reason),
+		})
+	}
+	return s
+}
+
+// LegendEntry — цвет схемы с количеством алмазов.
+type LegendEntry struct {
+	Color
+	Count int `json:"count"`
+}
+
+// Legend возвращает цвета схемы с количеством алмазов в порядке таблицы цветов.
+// Цвета, которых нет в сетке, пропускаются.
+func (s *Scheme) Legend() []LegendEntry {
+	counts := make([]int, len(s.Colors))
+	for _, row := range s.Grid {
+		for _, i := range row {
+			if i >= 0 && i < len(counts) {
+				counts[i]++
+			}
+		}
+	}
+	legend := make([]LegendEntry, 0, len(s.Colors))
+	for i, c := range s.Colors {
+		if counts[i] > 0 {
+			legend = append(legend, LegendEntry{Color: c, Count: counts[i]})
+		}
+	}
+	return legend
+}
+
+// newColor переводит цвет палитры в запись таблицы цветов.
+func newColor(pc db.PaletteColor) Color {
+	r, g, b := pc.Color.RGB255()
+	return Color{
+		DMCCode: pc.DMCCode,
+		Name:    pc.Name,
+		RGB:     [3]int{int(r), int(g), int(b)},
+		Symbol:  pc.Symbol,
+	}
+}
+
+// paletteColor переводит запись таблицы цветов обратно в цвет палитры.
+func (c Color) paletteColor() db.PaletteColor {
+	return db.PaletteColor{
+		DMCCode: c.DMCCode,
+		Name:    c.Name,
+		Color: colorful.Color{
+			R: float64(c.RGB[0]) / 255.0,
+			G: float64(c.RGB[1]) / 255.0,
+			B: float64(c.RGB[2]) / 255.0,
+		},
+		Symbol: c.Symbol,
+	}
+}
+
+// Matched восстанавливает сетку цветов палитры (с символами).
+func (s *Scheme) Matched() [][]db.PaletteColor {
+	colors := make([]db.PaletteColor, len(s.Colors))
+	for i, c := range s.Colors {
+		colors[i] = c.paletteColor()
+	}
+	blank := imagepkg.BlankColor()
+
+	matched := make([][]db.PaletteColor, s.Height)
+	for y, row := range s.Grid {
+		matched[y] = make([]db.PaletteColor, len(row))
+		for x, i := range row {
+			if i == blankIndex {
+				matched[y][x] = blank
+			} else {
+				matched[y][x] = colors[i]
+			}
+		}
+	}
+	return matched
+}
+
+// Result заново рисует схему и возвращает результат, пригодный для любого экспорта.
+func (s *Scheme) Result() imagepkg.Result {
+	matched := s.Matched()
+	mosaic, usages := imagepkg.RenderScheme(matched)
+	res := imagepkg.Result{
+		Mosaic:  mosaic,
+		Matched: matched,
+		Usages:  usages,
+		Size:    s.Size,
+		Params:  s.Params,
+	}
+	for _, sub := range s.Substitutions {
+		res.Substitutions = append(res.Substitutions, imagepkg.Substitution{
+			From:   sub.From.paletteColor(),
+			To:     sub.To.paletteColor(),
+			Count:  sub.Count,
+			Reason: imagepkg.SubstitutionReason(sub.Reason),
+		})
+	}
+	return res
+}
+
+// Validate проверяет согласованность схемы после чтения.
+func (s *Scheme) Validate() error {
+	if s.Format != FormatName {
+		return fmt.Errorf("файл не является схемой %s", FormatName)
+	}
+	if s.Version < 1 || s.Version > Version {
+		return fmt.Errorf("неподдерживаемая версия схемы %d (поддерживаются 1–%d)", s.Version, Version)
+	}
+	if s.Width <= 0 || s.Height <= 0 || len(s.Grid) != s.Height {
+		return fmt.Errorf("некорректные размеры сетки %dx%d", s.Width, s.Height)
+	}
+	for y, row := range s.Grid {
+		if len(row) != s.Width {
+			return fmt.Errorf("строка %d: ожидается %d клеток, получено %d", y, s.Width, len(row))
+		}
+		for x, i := range row {
+			if i != blankIndex && (i < 0 || i >= len(s.Colors)) {
+				return fmt.Errorf("клетка (%d, %d): неизвестный индекс цвета %d", x, y, i)
+			}
+		}
+	}
+	return nil
+}
+
+// Write записывает схему; при compress == true файл сжимается gzip.
+func Write(w io.Writer, s *Scheme, compress bool) error {
+	if !compress {
+		return json.NewEncoder(w).Encode(s)
+	}
+	zw := gzip.NewWriter(w)
+	zw.Name = "mosaic" + Extension
+	if err := json.NewEncoder(zw).Encode(s); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// Read читает схему в формате JSON или gzip (определяется по содержимому) и проверяет её.
+func Read(r io.Reader) (*Scheme, error) {
+	br := bufio.NewReader(r)
+	var src io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка распаковки схемы: %w", err)
+		}
+		defer zr.Close()
+		src = zr
+	}
+
+	var s Scheme
+	if err := json.NewDecoder(src).Decode(&s); err != nil {
+		return nil, fmt.Errorf("ошибка чтения схемы: %w", err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
@@ -0,0 +1,131 @@
+package scheme
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"diamond-mosaic/internal/db"
+	imagepkg "diamond-mosaic/internal/image"
+
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+// testResult собирает небольшой результат генерации с пустыми клетками и заменой цвета.
+func testResult() imagepkg.Result {
+	red := db.PaletteColor{DMCCode: "321", Name: "Red", Color: colorful.Color{R: 199.0 / 255, G: 43.0 / 255, B: 59.0 / 255}, Symbol: "●"}
+	blue := db.PaletteColor{DMCCode: "820", Name: "Royal Blue", Color: colorful.Color{R: 14.0 / 255, G: 54.0 / 255, B: 154.0 / 255}, Symbol: "■"}
+	gone := db.PaletteColor{DMCCode: "666", Name: "Bright Red", Color: colorful.Color{R: 227.0 / 255, G: 29.0 / 255, B: 66.0 / 255}}
+	blank := imagepkg.BlankColor()
+
+	matched := [][]db.PaletteColor{
+		{blank, red, red},
+		{blue, red, blank},
+	}
+	opts := imagepkg.DefaultOptions()
+	opts.DrillSizeMM = 2.8
+	return imagepkg.Result{
+		Matched: matched,
+		Usages:  imagepkg.CountUsages(matched),
+		Params:  imagepkg.Params{WidthCM: 30, HeightCM: 20, Options: opts, SourceSHA256: "abc", PaletteVersion: "v1"},
+		Substitutions: []imagepkg.Substitution{
+			{From: gone, To: red, Count: 2, Reason: imagepkg.ReasonOutOfStock},
+		},
+	}
+}
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	res := testResult()
+	for _, compress := range []bool{false, true} {
+		s := FromResult(res)
+		var buf bytes.Buffer
+		if err := Write(&buf, s, compress); err != nil {
+			t.Fatal(err)
+		}
+		if gz := buf.Len() > 1 && buf.Bytes()[0] == 0x1f && buf.Bytes()[1] == 0x8b; gz != compress {
+			t.Errorf("compress=%v: файл сжат = %v", compress, gz)
+		}
+
+		got, err := Read(&buf)
+		if err != nil {
+			t.Fatalf("compress=%v: %v", compress, err)
+		}
+		if !got.CreatedAt.Equal(s.CreatedAt) {
+			t.Errorf("compress=%v: created_at %v, ожидалось %v", compress, got.CreatedAt, s.CreatedAt)
+		}
+		got.CreatedAt = s.CreatedAt
+		if !reflect.DeepEqual(got, s) {
+			t.Errorf("compress=%v: схема после чтения отличается:\n%+v\nожидалось\n%+v", compress, got, s)
+		}
+
+		// Сетка восстанавливается с теми же кодами и символами
+		matched := got.Matched()
+		for y := range res.Matched {
+			for x, want := range res.Matched[y] {
+				if pc := matched[y][x]; pc.DMCCode != want.DMCCode || pc.Symbol != want.Symbol || pc.Color.Hex() != want.Color.Hex() {
+					t.Errorf("клетка (%d, %d) = %s %q %s, ожидалось %s %q %s", x, y,
+						pc.DMCCode, pc.Symbol, pc.Color.Hex(), want.DMCCode, want.Symbol, want.Color.Hex())
+				}
+			}
+		}
+	}
+}
+
+func TestFromResult(t *testing.T) {
+	s := FromResult(testResult())
+	if s.Width != 3 || s.Height != 2 {
+		t.Errorf("размер %dx%d, ожидалось 3x2", s.Width, s.Height)
+	}
+	if want := [][]int{{blankIndex, 0, 0}, {1, 0, blankIndex}}; !reflect.DeepEqual(s.Grid, want) {
+		t.Errorf("сетка %v, ожидалось %v", s.Grid, want)
+	}
+	if want := []LegendEntry{{Color: s.Colors[0], Count: 3}, {Color: s.Colors[1], Count: 1}}; !reflect.DeepEqual(s.Legend(), want) {
+		t.Errorf("легенда %+v, ожидалось %+v", s.Legend(), want)
+	}
+	if len(s.Substitutions) != 1 || s.Substitutions[0].To.Symbol != "●" || s.Substitutions[0].Reason != "out_of_stock" {
+		t.Errorf("замены %+v: ожидалась замена 666 на 321 с символом ●", s.Substitutions)
+	}
+	if s.Drill.SizeMM != 2.8 || s.Palette.Version != "v1" {
+		t.Errorf("профиль алмаза %+v, палитра %+v", s.Drill, s.Palette)
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	valid := FromResult(testResult())
+	tests := []struct {
+		name   string
+		modify func(s *Scheme)
+		want   string // подстрока ошибки
+	}{
+		{"другой формат", func(s *Scheme) { s.Format = "other" }, "не является схемой"},
+		{"версия из будущего", func(s *Scheme) { s.Version = Version + 1 }, "версия"},
+		{"нулевая версия", func(s *Scheme) { s.Version = 0 }, "версия"},
+		{"пустая сетка", func(s *Scheme) { s.Grid = nil }, "размеры"},
+		{"короткая строка", func(s *Scheme) { s.Grid[1] = s.Grid[1][:2] }, "строка 1"},
+		{"неизвестный индекс", func(s *Scheme) { s.Grid[0][1] = len(s.Colors) }, "индекс цвета"},
+		{"отрицательный индекс", func(s *Scheme) { s.Grid[0][1] = -2 }, "индекс цвета"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := *valid
+			s.Grid = [][]int{append([]int(nil), valid.Grid[0]...), append([]int(nil), valid.Grid[1]...)}
+			tt.modify(&s)
+			var buf bytes.Buffer
+			if err := Write(&buf, &s, false); err != nil {
+				t.Fatal(err)
+			}
+			_, err := Read(&buf)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ошибка %v, ожидалась с %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := Read(strings.NewReader("не json")); err == nil {
+		t.Error("ожидалась ошибка чтения не-JSON")
+	}
+	if _, err := Read(bytes.NewReader([]byte{0x1f, 0x8b, 0, 0})); err == nil {
+		t.Error("ожидалась ошибка распаковки повреждённого gzip")
+	}
+}
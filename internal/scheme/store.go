@@ -0,0 +1,120 @@
+package scheme
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNotFound возвращается, если схемы с таким идентификатором нет.
+var ErrNotFound = errors.New("схема не найдена")
+
+// Store хранит сгенерированные схемы по идентификатору.
+type Store interface {
+	Save(s *Scheme) (id string, err error)
+	Get(id string) (*Scheme, error)
+	// List возвращает схемы от новых к старым: не больше limit, пропустив offset первых.
+	List(limit, offset int) ([]Summary, error)
+}
+
+// Summary — краткие сведения о сохранённой схеме.
+type Summary struct {
+	ID             string    `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	WidthCM        int       `json:"width_cm"`
+	HeightCM       int       `json:"height_cm"`
+	GridWidth      int       `json:"grid_width"`
+	GridHeight     int       `json:"grid_height"`
+	Colors         int       `json:"colors"`
+	Drills         int       `json:"drills"`
+	SourceSHA256   string    `json:"source_sha256,omitempty"`
+	PaletteVersion string    `json:"palette_version,omitempty"`
+}
+
+// Summary собирает краткие сведения о схеме с идентификатором id.
+func (s *Scheme) Summary(id string) Summary {
+	sum := Summary{
+		ID:             id,
+		CreatedAt:      s.CreatedAt,
+		WidthCM:        s.Params.WidthCM,
+		HeightCM:       s.Params.HeightCM,
+		GridWidth:      s.Width,
+		GridHeight:     s.Height,
+		SourceSHA256:   s.Params.SourceSHA256,
+		PaletteVersion: s.Palette.Version,
+	}
+	for _, e := range s.Legend() {
+		sum.Colors++
+		sum.Drills += e.Count
+	}
+	return sum
+}
+
+// MemoryStore — хранилище последних схем в памяти процесса.
+// При переполнении вытесняются самые старые схемы.
+type MemoryStore struct {
+	mu      sync.Mutex
+	limit   int
+	order   []string
+	schemes map[string]*Scheme
+}
+
+// NewMemoryStore создаёт хранилище не более чем на limit схем.
+func NewMemoryStore(limit int) *MemoryStore {
+	return &MemoryStore{
+		limit:   limit,
+		schemes: map[string]*Scheme{},
+	}
+}
+
+// Save сохраняет схему и возвращает её новый идентификатор.
+func (m *MemoryStore) Save(s *Scheme) (string, error) {
+	id, err := NewID()
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.schemes[id] = s
+	m.order = append(m.order, id)
+	for len(m.order) > m.limit {
+		delete(m.schemes, m.order[0])
+		m.order = m.order[1:]
+	}
+	return id, nil
+}
+
+// Get возвращает схему по идентификатору.
+func (m *MemoryStore) Get(id string) (*Scheme, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.schemes[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s, nil
+}
+
+// List возвращает схемы от новых к старым.
+func (m *MemoryStore) List(limit, offset int) ([]Summary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var list []Summary
+	for i := len(m.order) - 1 - offset; i >= 0 && len(list) < limit; i-- {
+		id := m.order[i]
+		list = append(list, m.schemes[id].Summary(id))
+	}
+	return list, nil
+}
+
+// NewID создаёт случайный идентификатор схемы (32 шестнадцатеричных символа).
+func NewID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
@@ -30,22 +30,92 @@ document.getElementById("uploadForm").addEventListener("submit", async function
 
     if (!response.ok) throw new Error("Ошибка при генерации схемы");
 
+    // Имя файла берём из Content-Disposition, расширение зависит от формата
+    const output = formData.get("output") || "pdf";
+    const disposition = response.headers.get("Content-Disposition") || "";
+    const match = disposition.match(/filename="([^"]+)"/);
+    const fileName = match ? match[1] : "mosaic." + output;
+
     const blob = await response.blob();
     const url = window.URL.createObjectURL(blob);
 
     const a = document.createElement("a");
     a.href = url;
-    a.download = "mosaic.pdf";
+    a.download = fileName;
     a.click();
     window.URL.revokeObjectURL(url);
 
-    status.textContent = "Готово! PDF-файл скачан.";
+    status.textContent = "Готово! Файл " + fileName + " скачан.";
   } catch (err) {
     console.error(err);
     status.textContent = "Произошла ошибка при генерации схемы.";
   }
 });
 
+// Предпросмотр: обновляется при смене файла, размеров и параметров генерации.
+// Запросы откладываются на время ввода, а устаревший запрос отменяется.
+(function () {
+  const form = document.getElementById("uploadForm");
+  const block = document.getElementById("preview");
+  const image = document.getElementById("previewImage");
+  const stats = document.getElementById("previewStats");
+  let timer = null;
+  let controller = null;
+  let imageUrl = null;
+
+  async function updatePreview() {
+    const input = form.querySelector('input[name="file"]');
+    const width = form.querySelector('input[name="width"]');
+    const height = form.querySelector('input[name="height"]');
+    if (!input.files || input.files.length === 0 || input.files[0].type !== "image/png" ||
+        !width.checkValidity() || !height.checkValidity() || !width.value || !height.value) {
+      return;
+    }
+
+    if (controller) controller.abort();
+    controller = new AbortController();
+    stats.textContent = "Обновляем предпросмотр...";
+    block.hidden = false;
+
+    try {
+      const response = await fetch("/preview", {
+        method: "POST",
+        body: new FormData(form),
+        signal: controller.signal,
+      });
+      if (!response.ok) throw new Error(await response.text());
+
+      const blob = await response.blob();
+      if (imageUrl) window.URL.revokeObjectURL(imageUrl);
+      imageUrl = window.URL.createObjectURL(blob);
+      image.src = imageUrl;
+
+      const drills = Number(response.headers.get("X-Preview-Drills") || 0);
+      stats.textContent = "Сетка " + (response.headers.get("X-Preview-Grid") || "") +
+        " · цветов: " + response.headers.get("X-Preview-Colors") +
+        " · алмазов: " + drills.toLocaleString("ru-RU") +
+        " · ΔE: среднее " + response.headers.get("X-Quality-Mean-DeltaE") +
+        ", макс. " + response.headers.get("X-Quality-Max-DeltaE");
+    } catch (err) {
+      if (err.name === "AbortError") return;
+      console.error(err);
+      stats.textContent = "Не удалось построить предпросмотр.";
+    }
+  }
+
+  function schedule() {
+    clearTimeout(timer);
+    timer = setTimeout(updatePreview, 400);
+  }
+
+  form.addEventListener("input", function (e) {
+    if (e.target.name !== "output") schedule();
+  });
+  form.addEventListener("change", function (e) {
+    if (e.target.name !== "output") schedule();
+  });
+})();
+
 document.addEventListener("DOMContentLoaded", function() {
   const fileInput = document.querySelector('.file-label input[type="file"]');
   const customText = document.querySelector('.file-custom-text');
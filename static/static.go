@@ -0,0 +1,9 @@
+// Package static встраивает файлы веб-интерфейса (HTML, CSS, JS) в бинарник.
+package static
+
+import "embed"
+
+// Files — встроенные файлы фронтенда.
+//
+//go:embed index.html script.js style.css
+var Files embed.FS